@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ResetScores deletes every run and its session, profiles with their settings and unlocks stay
+func (store *SQLiteStore) ResetScores(ctx context.Context) (int, error) {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("reset scores: %w", err)
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"replays", "review_queue", "received_submissions", "sessions"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return 0, fmt.Errorf("reset scores: %w", err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM players")
+	if err != nil {
+		return 0, fmt.Errorf("reset scores: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return int(deleted), tx.Commit()
+}
+
+// RenamePlayer gives a profile a new name, its runs already on the board take the new name too
+func (store *SQLiteStore) RenamePlayer(ctx context.Context, oldName string, newName string) error {
+	oldName, newName = strings.TrimSpace(oldName), strings.TrimSpace(newName)
+	if newName == "" {
+		return fmt.Errorf("rename %q: the new name is empty", oldName)
+	}
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("rename %q: %w", oldName, err)
+	}
+	defer tx.Rollback()
+	// looked up in the transaction, which holds the write lock, so nobody takes the new name in between
+	profile, found, err := findProfile(ctx, tx, oldName)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("rename %q: no such player", oldName)
+	}
+	if other, taken, err := findProfile(ctx, tx, newName); err != nil {
+		return err
+	} else if taken && other.id != profile.id {
+		return fmt.Errorf("rename %q: %q is already taken", oldName, other.name)
+	}
+	// the name is part of every run's signature
+	rows, err := tx.QueryContext(ctx, "SELECT player_num FROM players WHERE profile_id = ?", profile.id)
+	if err != nil {
+		return fmt.Errorf("rename %q: %w", oldName, err)
+	}
+	var runs []int
+	for rows.Next() {
+		var playerNum int
+		if err := rows.Scan(&playerNum); err != nil {
+			rows.Close()
+			return fmt.Errorf("rename %q: %w", oldName, err)
+		}
+		runs = append(runs, playerNum)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rename %q: %w", oldName, err)
+	}
+	matched := make([]bool, len(runs))
+	for i, playerNum := range runs {
+		if matched[i], err = store.matches(ctx, tx, playerNum); err != nil {
+			return fmt.Errorf("rename %q: %w", oldName, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE profiles SET name = ? WHERE profile_id = ?", newName, profile.id); isUnique(err) {
+		return fmt.Errorf("rename %q: %q is already taken", oldName, newName)
+	} else if err != nil {
+		return fmt.Errorf("rename %q: %w", oldName, err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE players SET player_name = ? WHERE profile_id = ?", newName, profile.id); err != nil {
+		return fmt.Errorf("rename %q: %w", oldName, err)
+	}
+	for i, playerNum := range runs {
+		if err := store.sign(ctx, tx, playerNum, matched[i]); err != nil {
+			return fmt.Errorf("rename %q: %w", oldName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeletePlayer removes a profile and everything recorded for it and returns how many runs went with it
+func (store *SQLiteStore) DeletePlayer(ctx context.Context, name string) (int, error) {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("delete %q: %w", name, err)
+	}
+	defer tx.Rollback()
+	profile, found, err := findProfile(ctx, tx, name)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("delete %q: no such player", name)
+	}
+	// replays and reviews only know their run, player numbers get used again so they go first
+	for _, table := range []string{"replays", "review_queue"} {
+		statement := "DELETE FROM " + table + " WHERE player_num IN (SELECT player_num FROM players WHERE profile_id = ?)"
+		if _, err := tx.ExecContext(ctx, statement, profile.id); err != nil {
+			return 0, fmt.Errorf("delete %q from %s: %w", name, table, err)
+		}
+	}
+	for _, table := range []string{"sessions", "players", "profile_settings", "unlocks", "profiles"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE profile_id = ?", profile.id); err != nil {
+			return 0, fmt.Errorf("delete %q from %s: %w", name, table, err)
+		}
+	}
+	return profile.runs, tx.Commit()
+}
+
+func (store *SQLiteStore) Vacuum(ctx context.Context) error {
+	if _, err := store.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	return nil
+}
+
+// Check runs SQLite's own integrity and foreign key checks and returns every problem they find
+func (store *SQLiteStore) Check(ctx context.Context) ([]string, error) {
+	var problems []string
+	rows, err := store.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, fmt.Errorf("integrity check: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+
+	keys, err := store.db.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, fmt.Errorf("foreign key check: %w", err)
+	}
+	defer keys.Close()
+	for keys.Next() {
+		var table, parent string
+		var rowid, index interface{}
+		if err := keys.Scan(&table, &rowid, &parent, &index); err != nil {
+			return nil, fmt.Errorf("foreign key check: %w", err)
+		}
+		problems = append(problems, fmt.Sprintf("%s row %v points at a missing %s", table, rowid, parent))
+	}
+	return problems, keys.Err()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// migratedStore is a new SQLite store with every migration applied
+func migratedStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	db, file := openTestDatabase(t)
+	store := NewSQLiteStore(db, file, randomScoreKey())
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestRenamePlayer(t *testing.T) {
+	store := migratedStore(t)
+	ctx := context.Background()
+	for _, name := range []string{"Huy", "Ana"} {
+		profile, err := store.EnsureProfile(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.AddPlayer(ctx, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.RenamePlayer(ctx, "huy", "ANA"); err == nil || !strings.Contains(err.Error(), `"Ana" is already taken`) {
+		t.Fatalf("renaming onto another player: %v", err)
+	}
+	if err := store.RenamePlayer(ctx, "nobody", "Bo"); err == nil || !strings.Contains(err.Error(), "no such player") {
+		t.Fatalf("renaming nobody: %v", err)
+	}
+	if err := store.RenamePlayer(ctx, "huy", "HUY"); err != nil {
+		t.Fatalf("changing the case of a name: %v", err)
+	}
+	if got := dump(t, store.db, "SELECT name FROM profiles ORDER BY profile_id") +
+		"/" + dump(t, store.db, "SELECT player_name FROM players ORDER BY player_num"); got != "HUY\nAna/HUY\nAna" {
+		t.Fatalf("names after renaming %q", got)
+	}
+	if problems, err := store.CheckSignatures(ctx); err != nil || len(problems) != 0 {
+		t.Fatalf("renamed runs are not signed again: %v %v", problems, err)
+	}
+}
+
+func TestIsUnique(t *testing.T) {
+	store := migratedStore(t)
+	if _, err := store.db.Exec("INSERT INTO profiles (name) VALUES ('Huy'), ('huy')"); !isUnique(err) {
+		t.Fatalf("a second profile named huy: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const keepBackups = 10
+
+// backups live in a folder next to the database
+func backupDir(file string) string {
+	return filepath.Join(filepath.Dir(file), "backups")
+}
+
+// Backup writes a copy of the database with VACUUM INTO, which is safe while the game has it open.
+// The reason ends up in the file name, e.g. GameDatabase-20201103-141500-migrate.db.
+func (store *SQLiteStore) Backup(ctx context.Context, reason string) (string, error) {
+	dir := backupDir(store.file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("backup: %w", err)
+	}
+	ext := filepath.Ext(store.file)
+	name := strings.TrimSuffix(filepath.Base(store.file), ext) + "-" + time.Now().Format("20060102-150405") + "-" + reason
+	backup := filepath.Join(dir, name+ext)
+	for i := 2; DbExists(backup); i++ {
+		backup = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, ext))
+	}
+	if _, err := store.db.ExecContext(ctx, "VACUUM INTO ?", backup); err != nil {
+		return "", fmt.Errorf("backup to %s: %w", backup, err)
+	}
+
+	// only the newest few are worth keeping
+	backups, err := listBackups(store.file)
+	for err == nil && len(backups) > keepBackups {
+		err = os.Remove(backups[0])
+		backups = backups[1:]
+	}
+	return backup, err
+}
+
+// listBackups returns the backups of the database, oldest first
+func listBackups(file string) ([]string, error) {
+	files, err := ioutil.ReadDir(backupDir(file))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	ext := filepath.Ext(file)
+	prefix := strings.TrimSuffix(filepath.Base(file), ext) + "-"
+	var found []os.FileInfo
+	for _, info := range files {
+		if !info.IsDir() && strings.HasPrefix(info.Name(), prefix) && filepath.Ext(info.Name()) == ext {
+			found = append(found, info)
+		}
+	}
+	// names only go down to the second, two backups in the same second are told apart by when they were written
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].ModTime().Before(found[j].ModTime())
+	})
+	var backups []string
+	for _, info := range found {
+		backups = append(backups, filepath.Join(backupDir(file), info.Name()))
+	}
+	return backups, nil
+}
+
+// checkDatabaseFile opens a database just to run the integrity checks on it
+func checkDatabaseFile(ctx context.Context, file string) ([]string, error) {
+	if !DbExists(file) {
+		return nil, fmt.Errorf("no database at %s", file)
+	}
+	db, err := OpenDatabase(file)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// only the file's structure is checked, so no key is needed
+	return NewSQLiteStore(db, file, nil).Check(ctx)
+}
+
+// setAside renames a database out of the way so it is never lost, and returns its new name
+func setAside(file string, why string) (string, error) {
+	if !DbExists(file) {
+		return "", nil
+	}
+	aside := file + "." + why + "-" + time.Now().Format("20060102-150405")
+	for i := 2; DbExists(aside); i++ {
+		aside = fmt.Sprintf("%s.%s-%s-%d", file, why, time.Now().Format("20060102-150405"), i)
+	}
+	if err := os.Rename(file, aside); err != nil {
+		return "", fmt.Errorf("move %s aside: %w", file, err)
+	}
+	// the journal files go with it, the WAL can hold writes the main file does not have yet
+	for _, journal := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Rename(file+journal, aside+journal); err != nil && !os.IsNotExist(err) {
+			return aside, fmt.Errorf("move %s aside: %w", file+journal, err)
+		}
+	}
+	return aside, nil
+}
+
+// claimDatabase makes sure nothing else has the database open. SQLite only takes a database out of WAL mode
+// when no other connection is using it, so a copy of the game or "serve" still running makes it fail.
+func claimDatabase(ctx context.Context, file string) error {
+	if !DbExists(file) {
+		return nil
+	}
+	db, err := sql.Open("sqlite3", file+"?_busy_timeout=0")
+	if err != nil {
+		return fmt.Errorf("claim %s: %w", file, err)
+	}
+	defer db.Close()
+	var mode string
+	err = db.QueryRowContext(ctx, "PRAGMA journal_mode=DELETE").Scan(&mode)
+	if isBusy(err) {
+		return fmt.Errorf("%s is open in another copy of the game, close it first", file)
+	}
+	// any other error is the damage the restore is for, it does not stop it
+	return nil
+}
+
+// RestoreDatabase puts a backup in the database's place once the backup itself checks out,
+// it refuses while anything else has the database open
+func RestoreDatabase(ctx context.Context, backup string, file string) error {
+	problems, err := checkDatabaseFile(ctx, backup)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", backup, err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("restore %s: the backup is damaged too: %s", backup, problems[0])
+	}
+	if err := claimDatabase(ctx, file); err != nil {
+		return fmt.Errorf("restore %s: %w", backup, err)
+	}
+	if _, err := setAside(file, "before-restore"); err != nil {
+		return fmt.Errorf("restore %s: %w", backup, err)
+	}
+	if err := copyFile(backup, file); err != nil {
+		return fmt.Errorf("restore %s: %w", backup, err)
+	}
+	return nil
+}
+
+// backupTime reads the time back out of a backup's name for showing to the player
+func backupTime(backup string, file string) string {
+	ext := filepath.Ext(file)
+	stamp := strings.TrimPrefix(filepath.Base(backup), strings.TrimSuffix(filepath.Base(file), ext)+"-")
+	if len(stamp) < len("20060102-150405") {
+		return filepath.Base(backup)
+	}
+	when, err := time.ParseInLocation("20060102-150405", stamp[:len("20060102-150405")], time.Local)
+	if err != nil {
+		return filepath.Base(backup)
+	}
+	return when.Format("2006-01-02 15:04")
+}
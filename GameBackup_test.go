@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSetAsideTakesTheWAL(t *testing.T) {
+	store := migratedStore(t)
+	ctx := context.Background()
+	profile, err := store.EnsureProfile(ctx, "Huy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddPlayer(ctx, profile); err != nil {
+		t.Fatal(err)
+	}
+	if !DbExists(store.file + "-wal") {
+		t.Fatal("the open database has no WAL to move")
+	}
+
+	aside, err := setAside(store.file, "damaged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	if DbExists(store.file) || DbExists(store.file+"-wal") || DbExists(store.file+"-shm") {
+		t.Fatal("parts of the database were left behind")
+	}
+	db, err := OpenDatabase(aside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got := dump(t, db, "SELECT player_name FROM players"); got != "Huy" {
+		t.Fatalf("the database set aside has players %q", got)
+	}
+}
+
+func TestRestoreRefusesAnOpenDatabase(t *testing.T) {
+	store := migratedStore(t)
+	ctx := context.Background()
+	if _, err := store.EnsureProfile(ctx, "Huy"); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := store.Backup(ctx, "manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.EnsureProfile(ctx, "Ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = RestoreDatabase(ctx, backup, store.file)
+	if err == nil || !strings.Contains(err.Error(), "open in another copy of the game") {
+		t.Fatalf("restore with the database open: %v", err)
+	}
+	if got := dump(t, store.db, "SELECT name FROM profiles ORDER BY profile_id"); got != "Huy\nAna" {
+		t.Fatalf("a refused restore changed the database: %q", got)
+	}
+
+	store.Close()
+	if err := RestoreDatabase(ctx, backup, store.file); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDatabase(store.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got := dump(t, db, "SELECT name FROM profiles ORDER BY profile_id"); got != "Huy" {
+		t.Fatalf("restored profiles %q", got)
+	}
+}
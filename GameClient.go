@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	serverEnvVar  = "PUPPEROOO_SERVER"
+	postTimeout   = 10 * time.Second
+	firstBackoff  = 2 * time.Second
+	maxBackoff    = 5 * time.Minute
+	outboxPoll    = 30 * time.Second // runs left by an earlier game or another copy sharing the database
+	postQueueSize = 16
+)
+
+var leaderboardURL string // set with -server or $PUPPEROOO_SERVER, empty plays without a leaderboard server
+
+// what the game over screen says about posting a run to the leaderboard server
+const (
+	PostSending  = "sending"
+	PostPosted   = "posted"
+	PostReview   = "review"   // the server has the run but holds it until someone reviews its replay
+	PostWaiting  = "waiting"  // the server could not be reached, the run waits in the outbox
+	PostRejected = "rejected" // the server turned the run down, it is not sent again
+)
+
+// PostStatus is what happened to one submission, the game keeps the one for the run it is showing
+type PostStatus struct {
+	key     string
+	state   string
+	rank    int
+	message string
+}
+
+// LeaderboardClient posts finished runs to a leaderboard server started with `serve`. Runs go through the outbox
+// in the store first, so one the server cannot take right now is tried again later, even after the game restarts.
+type LeaderboardClient struct {
+	url      string
+	store    ScoreStore
+	http     *http.Client
+	submit   chan Submission
+	status   chan PostStatus
+	ctx      context.Context
+	cancel   context.CancelFunc
+	finished chan struct{}
+}
+
+func NewLeaderboardClient(url string, store ScoreStore) *LeaderboardClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &LeaderboardClient{
+		url:      strings.TrimRight(url, "/"),
+		store:    store,
+		http:     &http.Client{Timeout: postTimeout},
+		submit:   make(chan Submission, postQueueSize),
+		status:   make(chan PostStatus, postQueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		finished: make(chan struct{}),
+	}
+	go client.work()
+	return client
+}
+
+// newSubmission makes the outbox entry for a finished run, its key is new so the server saves it once
+func newSubmission(player string, session Session, replay Replay) (Submission, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return Submission{}, fmt.Errorf("make idempotency key: %w", err)
+	}
+	record := sessionRecord(player, session)
+	record.Replay = replay.encode()
+	body, err := json.Marshal(scoreSubmission{RunRecord: record})
+	if err != nil {
+		return Submission{}, err
+	}
+	return Submission{key: hex.EncodeToString(random), body: string(body), nextTry: time.Now()}, nil
+}
+
+// Submit hands a run to the client without waiting, false means the queue is full and the run is not posted
+func (client *LeaderboardClient) Submit(submission Submission) bool {
+	select {
+	case client.submit <- submission:
+		return true
+	default:
+		return false
+	}
+}
+
+// Status returns what happened to a submission if the client has news, it never blocks
+func (client *LeaderboardClient) Status() (PostStatus, bool) {
+	select {
+	case status := <-client.status:
+		return status, true
+	default:
+		return PostStatus{}, false
+	}
+}
+
+// Close stops posting, runs handed over before it are in the outbox for next time
+func (client *LeaderboardClient) Close() {
+	client.cancel()
+	close(client.submit)
+	<-client.finished
+}
+
+func (client *LeaderboardClient) work() {
+	defer close(client.finished)
+	wait := time.Duration(0)
+	for {
+		select {
+		case submission, ok := <-client.submit:
+			if !ok {
+				return
+			}
+			client.queue(submission)
+		case <-time.After(wait):
+		}
+		if client.ctx.Err() != nil {
+			// closing, only save what is still being handed over
+			for submission := range client.submit {
+				client.queue(submission)
+			}
+			return
+		}
+		wait = client.flush()
+	}
+}
+
+func (client *LeaderboardClient) queue(submission Submission) {
+	ctx, cancel := dbContext()
+	defer cancel()
+	if err := client.store.QueueSubmission(ctx, submission); err != nil {
+		log.Println("leaderboard outbox:", err)
+		client.report(PostStatus{key: submission.key, state: PostRejected, message: "could not be queued"})
+		return
+	}
+	client.report(PostStatus{key: submission.key, state: PostSending})
+}
+
+// flush posts every run that is due and returns how long to wait before trying again
+func (client *LeaderboardClient) flush() time.Duration {
+	ctx, cancel := dbContext()
+	due, err := client.store.DueSubmissions(ctx, time.Now())
+	cancel()
+	if err != nil {
+		log.Println("leaderboard outbox:", err)
+		return outboxPoll
+	}
+	wait := outboxPoll
+	for _, submission := range due {
+		if client.ctx.Err() != nil {
+			break
+		}
+		status, retry := client.post(submission)
+		ctx, cancel := dbContext()
+		if retry {
+			submission.attempts++
+			submission.lastError = status.message
+			submission.nextTry = time.Now().Add(backoff(submission.attempts))
+			err = client.store.RetrySubmission(ctx, submission)
+			if untilNext := time.Until(submission.nextTry); untilNext < wait {
+				wait = untilNext
+			}
+		} else {
+			err = client.store.RemoveSubmission(ctx, submission.key)
+		}
+		cancel()
+		if err != nil {
+			log.Println("leaderboard outbox:", err)
+		}
+		client.report(status)
+	}
+	return wait
+}
+
+// backoff doubles the wait after every failed try up to maxBackoff, with some jitter
+// so copies of the game that lost the server together do not all come back at the same moment
+func backoff(attempts int) time.Duration {
+	wait := maxBackoff
+	if attempts < 20 {
+		if doubled := firstBackoff << uint(attempts-1); doubled < maxBackoff {
+			wait = doubled
+		}
+	}
+	return wait - time.Duration(mathrand.Int63n(int64(wait/4)+1))
+}
+
+// post sends one run, retry is true when the server could not take it right now and it should be sent again
+func (client *LeaderboardClient) post(submission Submission) (status PostStatus, retry bool) {
+	status = PostStatus{key: submission.key}
+	request, err := http.NewRequest(http.MethodPost, client.url+"/scores", strings.NewReader(submission.body))
+	if err != nil {
+		status.state, status.message = PostRejected, err.Error()
+		return status, false
+	}
+	request = request.WithContext(client.ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Idempotency-Key", submission.key)
+	response, err := client.http.Do(request)
+	if err != nil {
+		status.state, status.message = PostWaiting, "leaderboard server unreachable"
+		return status, true
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+
+	switch {
+	case response.StatusCode == http.StatusCreated || response.StatusCode == http.StatusAccepted:
+		var answer submissionJSON
+		json.Unmarshal(body, &answer)
+		status.state, status.rank = PostPosted, answer.Rank
+		if answer.Status == ReviewPending {
+			status.state, status.message = PostReview, answer.Reason
+		}
+		return status, false
+	case response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusRequestTimeout:
+		status.state, status.message = PostWaiting, "leaderboard server busy ("+response.Status+")"
+		return status, true
+	}
+	// any other answer will be the same next time, so the run is not sent again
+	var failure struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(body, &failure)
+	status.state, status.message = PostRejected, response.Status+" "+failure.Error
+	log.Println("leaderboard server turned a run down:", status.message)
+	return status, false
+}
+
+// report tells the game what happened, news the game has not picked up yet is dropped rather than waiting on it
+func (client *LeaderboardClient) report(status PostStatus) {
+	select {
+	case client.status <- status:
+	default:
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer is a leaderboard server that answers the first failures posts with status
+// and keeps the idempotency key of every post it gets
+type flakyServer struct {
+	lock     sync.Mutex
+	failures int
+	status   int
+	keys     []string
+	scores   *ScoreServer
+}
+
+func (server *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.lock.Lock()
+	server.keys = append(server.keys, r.Header.Get("Idempotency-Key"))
+	failing := len(server.keys) <= server.failures
+	server.lock.Unlock()
+	if failing {
+		writeError(w, server.status, "not now")
+		return
+	}
+	server.scores.ServeHTTP(w, r)
+}
+
+func (server *flakyServer) posted() []string {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return append([]string{}, server.keys...)
+}
+
+func newFlakyServer(t *testing.T, failures int, status int) (*flakyServer, *httptest.Server) {
+	flaky := &flakyServer{failures: failures, status: status, scores: NewScoreServer(NewMemoryStore())}
+	server := httptest.NewServer(flaky)
+	t.Cleanup(server.Close)
+	return flaky, server
+}
+
+// idleClient is a client that only posts when the test calls flush
+func idleClient(url string, store ScoreStore) *LeaderboardClient {
+	return &LeaderboardClient{url: url, store: store, http: &http.Client{Timeout: postTimeout}, ctx: context.Background(),
+		status: make(chan PostStatus, postQueueSize)}
+}
+
+// queuedRun puts a played run in the store's outbox and returns it
+func queuedRun(t *testing.T, store ScoreStore) Submission {
+	t.Helper()
+	replay, session := playRun(t, 2, 3000)
+	submission, err := newSubmission("Huy", session, replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.QueueSubmission(context.Background(), submission); err != nil {
+		t.Fatal(err)
+	}
+	return submission
+}
+
+// outbox is every run in the store's outbox, due or not
+func outbox(t *testing.T, store ScoreStore) []Submission {
+	t.Helper()
+	due, err := store.DueSubmissions(context.Background(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return due
+}
+
+// dueNow makes a run waiting in the outbox due straight away, as if its backoff had passed
+func dueNow(t *testing.T, store ScoreStore, submission Submission) {
+	t.Helper()
+	submission.nextTry = time.Now()
+	if err := store.RetrySubmission(context.Background(), submission); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientBacksOffWhileTheServerIsBusy(t *testing.T) {
+	flaky, server := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+	store := NewMemoryStore()
+	client := idleClient(server.URL, store)
+	run := queuedRun(t, store)
+
+	for attempt, want := range []time.Duration{firstBackoff, 2 * firstBackoff} {
+		client.flush()
+		if status, _ := client.Status(); status.state != PostWaiting || !strings.Contains(status.message, "503") {
+			t.Fatalf("try %d: %+v", attempt+1, status)
+		}
+		waiting := outbox(t, store)
+		if len(waiting) != 1 || waiting[0].attempts != attempt+1 {
+			t.Fatalf("try %d left %+v in the outbox", attempt+1, waiting)
+		}
+		// the wait doubles, less up to a quarter of jitter
+		if wait := time.Until(waiting[0].nextTry); wait > want || wait < want*3/4-time.Second {
+			t.Fatalf("try %d waits %s, want about %s", attempt+1, wait, want)
+		}
+		client.flush() // not due yet, nothing is sent
+		dueNow(t, store, waiting[0])
+	}
+	client.flush()
+	if status, _ := client.Status(); status.state != PostPosted || status.rank != 1 {
+		t.Fatalf("third try: %+v", status)
+	}
+	if waiting := outbox(t, store); len(waiting) != 0 {
+		t.Fatalf("a posted run is still in the outbox: %+v", waiting)
+	}
+	if keys := flaky.posted(); len(keys) != 3 || keys[0] != run.key || keys[1] != run.key || keys[2] != run.key {
+		t.Fatalf("posted with keys %q, want %q three times", keys, run.key)
+	}
+}
+
+func TestClientRetriesAnUnreachableServer(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close() // nothing answers at its address any more
+	store := NewMemoryStore()
+	client := idleClient(server.URL, store)
+	queuedRun(t, store)
+
+	client.flush()
+	if status, _ := client.Status(); status.state != PostWaiting || status.message != "leaderboard server unreachable" {
+		t.Fatalf("%+v", status)
+	}
+	if waiting := outbox(t, store); len(waiting) != 1 || waiting[0].attempts != 1 || time.Until(waiting[0].nextTry) <= 0 {
+		t.Fatalf("outbox %+v", waiting)
+	}
+}
+
+func TestClientDoesNotRetryRejections(t *testing.T) {
+	flaky, server := newFlakyServer(t, 1, http.StatusBadRequest)
+	store := NewMemoryStore()
+	client := idleClient(server.URL, store)
+	queuedRun(t, store)
+
+	client.flush()
+	if status, _ := client.Status(); status.state != PostRejected || !strings.Contains(status.message, "400") ||
+		!strings.Contains(status.message, "not now") {
+		t.Fatalf("%+v", status)
+	}
+	if waiting := outbox(t, store); len(waiting) != 0 {
+		t.Fatalf("a turned down run is still in the outbox: %+v", waiting)
+	}
+	client.flush()
+	if keys := flaky.posted(); len(keys) != 1 {
+		t.Fatalf("a turned down run was posted %d times", len(keys))
+	}
+}
+
+func TestOutboxOutlivesTheGame(t *testing.T) {
+	for _, backend := range []string{BackendJSON, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			if backend == BackendSQLite && !sqliteAvailable {
+				t.Skip("built without cgo, there is no SQLite")
+			}
+			file := filepath.Join(t.TempDir(), "scores")
+			open := func() ScoreStore {
+				store, err := OpenStore(backend, file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.Migrate(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				return store
+			}
+
+			// the server is down for the whole first game
+			down := httptest.NewServer(http.NotFoundHandler())
+			down.Close()
+			store := open()
+			client := NewLeaderboardClient(down.URL, store)
+			replay, session := playRun(t, 2, 3000)
+			run, err := newSubmission("Huy", session, replay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.Submit(run)
+			waitForPost(t, client, PostWaiting)
+			client.Close()
+			store.Close()
+
+			flaky, server := newFlakyServer(t, 0, 0)
+			store = open()
+			defer store.Close()
+			waiting := outbox(t, store)
+			if len(waiting) != 1 || waiting[0].key != run.key || waiting[0].attempts != 1 {
+				t.Fatalf("outbox after the restart %+v", waiting)
+			}
+			dueNow(t, store, waiting[0])
+			client = NewLeaderboardClient(server.URL, store)
+			waitForPost(t, client, PostPosted)
+			client.Close()
+			if waiting := outbox(t, store); len(waiting) != 0 {
+				t.Fatalf("a posted run is still in the outbox: %+v", waiting)
+			}
+			if keys := flaky.posted(); len(keys) != 1 || keys[0] != run.key {
+				t.Fatalf("posted with keys %q, want %q", keys, run.key)
+			}
+		})
+	}
+}
+
+// waitForPost waits for the client to report a run in state
+func waitForPost(t *testing.T, client *LeaderboardClient, state string) {
+	t.Helper()
+	var status PostStatus
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if news, ok := client.Status(); ok {
+			if status = news; status.state == state {
+				return
+			}
+		}
+	}
+	t.Fatalf("the run is %+v, want %s", status, state)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const commandUsage = `usage:
+  scores list [--top N] [--json]   print the all time high scores
+  scores reset --yes               delete every score, profiles are kept
+  scores export [--format csv|json] [--out file]
+                                   write every run and its session, to the screen without --out
+  scores import [--format csv|json] [--dry-run] <file>
+                                   add the runs from an export, runs already saved are skipped
+  scores review [accept|reject <run>]
+                                   list the runs that did not match their replays, or decide on one
+  player rename <old> <new>        rename a player and all of their scores
+  player delete <name>             delete a player and everything saved for them
+  db migrate                       bring the database up to the newest schema
+  db vacuum                        shrink the database file
+  db check                         look for damage in the database and scores changed outside the game
+  db backup                        write a dated copy of the database into the backups folder next to it
+  db restore [backup]              put a backup back, the newest one if none is named
+  serve [--addr host:port]         share the scores over HTTP, on :8080 unless --addr says otherwise
+a command's flags can go before or after its other arguments, anything after -- is not a flag
+with no command the game starts, before the command
+  -store sqlite|json|memory        picks how scores are kept
+  -db file                         picks the file they are kept in (or set $PUPPEROOO_DB)`
+
+// scoreJSON is how one leaderboard row looks in `scores list --json` and GET /scores
+type scoreJSON struct {
+	Rank       int    `json:"rank"`
+	Player     string `json:"player"`
+	Score      int    `json:"score"`
+	Level      int    `json:"level,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	PlayedAt   string `json:"played_at,omitempty"`
+	Tampered   bool   `json:"tampered,omitempty"`
+}
+
+// runCommand runs one of the database commands against the store and writes what it did to out,
+// everything but scores list, scores review and serve works on the SQLite database only
+func runCommand(args []string, store ScoreStore, out io.Writer) error {
+	ctx := context.Background()
+	if len(args) == 0 || len(args) < 2 && args[0] != "serve" {
+		return fmt.Errorf("%s", commandUsage)
+	}
+	command, flagArgs := args[0], args[1:]
+	if command != "serve" { // every other command is two words
+		command, flagArgs = command+" "+args[1], args[2:]
+	}
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(out)
+	top := flags.Int("top", 10, "how many scores to list")
+	asJSON := flags.Bool("json", false, "list the scores as JSON")
+	yes := flags.Bool("yes", false, "really delete every score")
+	format := flags.String("format", "", "csv or json, by default taken from the file name")
+	outFile := flags.String("out", "", "file to export to")
+	dryRun := flags.Bool("dry-run", false, "show what an import would do without saving it")
+	addr := flags.String("addr", defaultServeAddr, "address to serve scores on")
+	rest, err := parseFlags(flags, flagArgs)
+	if err != nil {
+		return err
+	}
+
+	// most commands need the current schema to work with, the rest must work on a damaged database
+	if command != "db migrate" && command != "db check" && command != "db backup" && command != "db restore" {
+		if err := store.Migrate(ctx); err != nil {
+			return err
+		}
+	}
+	if command == "scores list" {
+		return listScores(ctx, store, out, *top, *asJSON)
+	}
+	if command == "scores review" {
+		return reviewScores(ctx, store, out, rest)
+	}
+	if command == "serve" {
+		return serveScores(store, out, *addr)
+	}
+	sqlite, ok := store.(*SQLiteStore)
+	if !ok {
+		return fmt.Errorf("%s needs the sqlite store", command)
+	}
+
+	switch command {
+	case "scores reset":
+		if !*yes {
+			return fmt.Errorf("scores reset deletes every score, run it again with --yes to go ahead")
+		}
+		if err := backupFirst(ctx, sqlite, out, "reset"); err != nil {
+			return err
+		}
+		deleted, err := sqlite.ResetScores(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "deleted %d scores\n", deleted)
+
+	case "scores export":
+		return exportScores(ctx, sqlite, out, *format, *outFile)
+
+	case "scores import":
+		if len(rest) != 1 {
+			return fmt.Errorf("usage: scores import [--format csv|json] [--dry-run] <file>")
+		}
+		return importScores(ctx, sqlite, out, *format, rest[0], *dryRun)
+
+	case "player rename":
+		if len(rest) != 2 {
+			return fmt.Errorf("usage: player rename <old> <new>")
+		}
+		if err := sqlite.RenamePlayer(ctx, rest[0], rest[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "renamed %s to %s\n", rest[0], rest[1])
+
+	case "player delete":
+		if len(rest) != 1 {
+			return fmt.Errorf("usage: player delete <name>")
+		}
+		if err := backupFirst(ctx, sqlite, out, "delete"); err != nil {
+			return err
+		}
+		runs, err := sqlite.DeletePlayer(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "deleted %s and their %d runs\n", rest[0], runs)
+
+	case "db migrate":
+		applied, err := sqlite.migrate(ctx)
+		if err != nil {
+			return err
+		}
+		version, err := schemaVersion(ctx, sqlite.db)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migrations, schema version is %d\n", applied, version)
+
+	case "db vacuum":
+		if err := sqlite.Vacuum(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "vacuumed")
+
+	case "db check":
+		problems, err := sqlite.Check(ctx)
+		if err != nil {
+			return err
+		}
+		// db check does not migrate, a database from before signatures has none to check
+		if version, err := schemaVersion(ctx, sqlite.db); err != nil {
+			return err
+		} else if version >= signedSchema {
+			tampered, err := sqlite.CheckSignatures(ctx)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, tampered...)
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(out, problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("db check found %d problems", len(problems))
+		}
+		fmt.Fprintln(out, "ok")
+
+	case "db backup":
+		backup, err := sqlite.Backup(ctx, "manual")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "backed up to", backup)
+
+	case "db restore":
+		backup := ""
+		if len(rest) == 1 {
+			backup = rest[0]
+		} else if backups, err := listBackups(sqlite.file); err != nil {
+			return err
+		} else if len(backups) > 0 {
+			backup = backups[len(backups)-1]
+		} else {
+			return fmt.Errorf("there are no backups in %s", backupDir(sqlite.file))
+		}
+		sqlite.Close()
+		if err := RestoreDatabase(ctx, backup, sqlite.file); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "restored %s from %s\n", sqlite.file, backup)
+
+	default:
+		return fmt.Errorf("unknown command %q\n%s", command, commandUsage)
+	}
+	return nil
+}
+
+// parseFlags parses flags wherever they are among the arguments and returns the others,
+// flag stops at the first argument that is not a flag so `scores import runs.json --dry-run` would lose --dry-run.
+// Everything after -- is an argument, even if it starts with a dash.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		left := flags.Args()
+		if len(left) < len(args) && args[len(args)-len(left)-1] == "--" {
+			return append(rest, left...), nil
+		}
+		if len(left) == 0 {
+			return rest, nil
+		}
+		rest, args = append(rest, left[0]), left[1:]
+	}
+}
+
+func toScoreJSON(entry LeaderboardEntry) scoreJSON {
+	score := scoreJSON{Rank: entry.rank, Player: entry.name, Score: entry.score, Level: entry.level,
+		Difficulty: entry.difficulty, Mode: entry.mode, Tampered: entry.tampered}
+	if !entry.playedAt.IsZero() {
+		score.PlayedAt = entry.playedAt.Format(time.RFC3339)
+	}
+	return score
+}
+
+func listScores(ctx context.Context, store ScoreStore, out io.Writer, top int, asJSON bool) error {
+	entries, _, err := store.Leaderboard(ctx, LeaderboardQuery{window: WindowAllTime, now: time.Now(), limit: top})
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		scores := []scoreJSON{}
+		for _, entry := range entries {
+			scores = append(scores, toScoreJSON(entry))
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(scores)
+	}
+
+	fmt.Fprintf(out, "%-4s %-16s %7s %6s %-10s %-7s %s\n", "#", "player", "score", "level", "difficulty", "mode", "played")
+	for _, entry := range entries {
+		played := "-"
+		if !entry.playedAt.IsZero() {
+			played = entry.playedAt.Local().Format("2006-01-02 15:04")
+		}
+		if entry.tampered {
+			played += "  altered outside the game"
+		}
+		fmt.Fprintf(out, "%-4d %-16s %7d %6d %-10s %-7s %s\n", entry.rank, entry.name, entry.score, entry.level,
+			entry.difficulty, entry.mode, played)
+	}
+	return nil
+}
+
+// reviewScores lists the review queue, or accepts or rejects one run in it
+func reviewScores(ctx context.Context, store ScoreStore, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		reviews, err := store.ReviewQueue(ctx)
+		if err != nil {
+			return err
+		}
+		for _, review := range reviews {
+			fmt.Fprintln(out, review)
+		}
+		if len(reviews) == 0 {
+			fmt.Fprintln(out, "no runs waiting for review")
+		}
+		return nil
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: scores review [accept|reject <run>]")
+	}
+	status, err := reviewDecision(args[0])
+	if err != nil {
+		return err
+	}
+	playerNum, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("%q is not a run number", args[1])
+	}
+	if err := store.ReviewRun(ctx, playerNum, status); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "run %d %s\n", playerNum, status)
+	return nil
+}
+
+func backupFirst(ctx context.Context, store *SQLiteStore, out io.Writer, reason string) error {
+	backup, err := store.Backup(ctx, reason)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "backed up to", backup)
+	return nil
+}
+
+func exportScores(ctx context.Context, store *SQLiteStore, out io.Writer, format string, filename string) error {
+	format, err := exportFormat(format, filename)
+	if err != nil {
+		return err
+	}
+	records, err := store.ExportRuns(ctx)
+	if err != nil {
+		return err
+	}
+	if filename == "" {
+		return writeRuns(out, format, records)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := writeRuns(file, format, records); err != nil {
+		file.Close()
+		return fmt.Errorf("export %s: %w", filename, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("export %s: %w", filename, err)
+	}
+	fmt.Fprintf(out, "exported %d runs to %s\n", len(records), filename)
+	return nil
+}
+
+func importScores(ctx context.Context, store *SQLiteStore, out io.Writer, format string, filename string, dryRun bool) error {
+	format, err := exportFormat(format, filename)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	defer file.Close()
+	records, err := readRuns(file, format)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", filename, err)
+	}
+	imported, duplicates, err := store.ImportRuns(ctx, records, dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Fprintf(out, "dry run: would import %d runs, %d already saved\n", imported, duplicates)
+	} else {
+		fmt.Fprintf(out, "imported %d runs, skipped %d already saved\n", imported, duplicates)
+	}
+	return nil
+}
+
+// commandMain runs a command from the command line and returns the exit code
+func commandMain(backend string, dbfile string, args []string) int {
+	store, err := OpenStore(backend, dbfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer store.Close()
+	if err := runCommand(args, store, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportFlagsAfterTheFile(t *testing.T) {
+	records, _ := exportedRuns(t)
+	file := filepath.Join(t.TempDir(), "runs.txt") // no .json, so only --format says what it is
+	var exported bytes.Buffer
+	if err := writeRuns(&exported, "json", records); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, exported.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := migratedStore(t)
+	for _, args := range [][]string{
+		{"scores", "import", "--format", "json", "--dry-run", file},
+		{"scores", "import", file, "--dry-run", "--format", "json"},
+		{"scores", "import", "--format", "json", file, "--dry-run"},
+	} {
+		var out bytes.Buffer
+		if err := runCommand(args, store, &out); err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+		if got := out.String(); got != "dry run: would import 2 runs, 0 already saved\n" {
+			t.Fatalf("%q printed %q", args, got)
+		}
+	}
+	if got := dump(t, store.db, "SELECT COUNT(*) FROM players"); got != "0" {
+		t.Fatalf("dry runs saved %s runs", got)
+	}
+
+	var out bytes.Buffer
+	if err := runCommand([]string{"scores", "import", file, "--format", "json"}, store, &out); err != nil ||
+		out.String() != "imported 2 runs, skipped 0 already saved\n" {
+		t.Fatalf("importing printed %q: %v", out.String(), err)
+	}
+	if err := runCommand([]string{"scores", "import", file, "--dry-run", "extra.json"}, store, &out); err == nil {
+		t.Fatal("imported with two files named")
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args   []string
+		rest   []string
+		dryRun bool
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, false},
+		{[]string{"--dry-run", "a"}, []string{"a"}, true},
+		{[]string{"a", "--dry-run", "b"}, []string{"a", "b"}, true},
+		{[]string{"a", "b", "-dry-run"}, []string{"a", "b"}, true},
+		// a name that starts with a dash goes after --
+		{[]string{"a", "--", "-b", "--dry-run"}, []string{"a", "-b", "--dry-run"}, false},
+		{[]string{"--dry-run", "--", "-a"}, []string{"-a"}, true},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		dryRun := flags.Bool("dry-run", false, "")
+		rest, err := parseFlags(flags, test.args)
+		if err != nil || !reflect.DeepEqual(rest, test.rest) || *dryRun != test.dryRun {
+			t.Fatalf("%q: got %q dry run %t %v, want %q dry run %t", test.args, rest, *dryRun, err, test.rest, test.dryRun)
+		}
+	}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	if _, err := parseFlags(flags, []string{"a", "--nope"}); err == nil {
+		t.Fatal("an unknown flag after an argument was let through")
+	}
+}
@@ -1,171 +1,470 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
-	"log"
 	"os"
+	"time"
 )
 
-func Create_tables(database *sql.DB) {
-	create_game_table := "CREATE TABLE IF NOT EXISTS players(" +
-		"player_num INTEGER PRIMARY KEY," +
-		"player_name TEXT NOT NULL," +
-		"player_score INTEGER DEFAULT 0);"
-	database.Exec(create_game_table)
+// ScoreStore is everything the game needs to save and read back players and their scores
+type ScoreStore interface {
+	Migrate(ctx context.Context) error
+	FindProfile(ctx context.Context, playername string) (Profile, bool, error)
+	EnsureProfile(ctx context.Context, playername string) (Profile, error)
+	AddPlayer(ctx context.Context, profile Profile) (int, error)
+	UpdateScore(ctx context.Context, id int, score int) error
+	Leaderboard(ctx context.Context, query LeaderboardQuery) ([]LeaderboardEntry, int, error)
+	LeaderboardRank(ctx context.Context, query LeaderboardQuery, playerNum int) (LeaderboardEntry, bool, error)
+	LoadSettings(ctx context.Context, profileID int) (map[string]string, error)
+	SaveSettings(ctx context.Context, profileID int, settings map[string]string) error
+	Unlock(ctx context.Context, profileID int, unlock string) error
+	StartSession(ctx context.Context, session *Session) error
+	FinishSession(ctx context.Context, session Session) error
+	CheckpointSession(ctx context.Context, session Session) error
+	RecentSessions(ctx context.Context, profileID int, limit int) ([]Session, error)
+	PersonalBests(ctx context.Context, profileID int) (PersonalBests, error)
+	SubmitReplay(ctx context.Context, playerNum int, replay Replay) (string, error)
+	SaveReplay(ctx context.Context, playerNum int, replay Replay, reason string) error
+	ReviewQueue(ctx context.Context) ([]Review, error)
+	ReviewRun(ctx context.Context, playerNum int, status string) error
+	QueueSubmission(ctx context.Context, submission Submission) error
+	DueSubmissions(ctx context.Context, now time.Time) ([]Submission, error)
+	RetrySubmission(ctx context.Context, submission Submission) error
+	RemoveSubmission(ctx context.Context, key string) error
+	SubmissionAnswer(ctx context.Context, key string) (string, bool, error)
+	SaveSubmissionAnswer(ctx context.Context, key string, answer string) error
+	Close() error
 }
 
-func OpenDatabase(dbfile string) *sql.DB {
-	database, err := sql.Open("sqlite3", dbfile)
-	if err != nil {
-		log.Fatal("Open Database Error", err)
+// store backends that -store can pick
+const (
+	BackendSQLite = "sqlite"
+	BackendJSON   = "json"
+	BackendMemory = "memory"
+)
+
+func defaultBackend() string {
+	if sqliteAvailable {
+		return BackendSQLite
 	}
-	return database
+	return BackendJSON
 }
 
-func checkErr(err error, msg string) {
-	if err != nil {
-		log.Fatal(msg+" ", err)
+// OpenStore opens the score store for the backend, dbfile is where it keeps its data
+func OpenStore(backend string, dbfile string) (ScoreStore, error) {
+	switch backend {
+	case BackendSQLite:
+		key, err := loadScoreKey(dbfile)
+		if err != nil {
+			return nil, err
+		}
+		db, err := OpenDatabase(dbfile)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteStore(db, dbfile, key), nil
+	case BackendJSON:
+		key, err := loadScoreKey(dbfile)
+		if err != nil {
+			return nil, err
+		}
+		return NewJSONStore(dbfile, key)
+	case BackendMemory:
+		return NewMemoryStore(), nil
 	}
+	return nil, fmt.Errorf("unknown store %q, use %s, %s or %s", backend, BackendSQLite, BackendJSON, BackendMemory)
 }
 
-func DbExists(path string) bool {
-	//_, err := os.Stat(path)	// another way of writing it
-	//if err != nil {
-	//	return true
-	//}
-	//if _, err := os.Stat(path); err == nil{
-	//	fmt.Println("path exists")
-	//	return true
-	//}
+// SQLiteStore keeps the scores in a SQLite database opened by the caller
+type SQLiteStore struct {
+	db    *sql.DB
+	file  string // where backups go next to
+	key   ScoreKey
+	batch *sqliteBatch // the transaction every call runs in when the store is one of Batch's, nil otherwise
+}
 
-	_, err := os.Stat(path)
-	if os.IsExist(err) {
-		fmt.Println("file not exist")
-		return true
+func NewSQLiteStore(db *sql.DB, file string, key ScoreKey) *SQLiteStore {
+	return &SQLiteStore{db: db, file: file, key: key}
+}
+
+// sqlConn runs statements, it is the database, a transaction or a savepoint in a batch
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// storeTx is what a store call writes through, committed or rolled back as a whole
+type storeTx interface {
+	sqlConn
+	Commit() error
+	Rollback() error
+}
+
+type sqliteBatch struct {
+	tx         *sql.Tx
+	savepoints int
+	broken     error // a savepoint that could not be rolled back, the batch cannot be committed after it
+}
+
+// Batcher is a store that can run several writes in one transaction, the ScoreWriter uses it for each batch
+type Batcher interface {
+	// Batch runs the writes in order, each in a savepoint so one that fails takes back only its own part,
+	// and returns what each of them returned. A busy database fails the whole batch so it can be tried again.
+	Batch(ctx context.Context, writes []func(ctx context.Context, store ScoreStore) error) ([]error, error)
+}
+
+func (store *SQLiteStore) Batch(ctx context.Context, writes []func(ctx context.Context, store ScoreStore) error) ([]error, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("batch: %w", err)
+	}
+	defer tx.Rollback()
+	batched := *store
+	batched.batch = &sqliteBatch{tx: tx}
+	errs := make([]error, len(writes))
+	for i, write := range writes {
+		point, err := batched.begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("batch: %w", err)
+		}
+		if errs[i] = write(ctx, &batched); isBusy(errs[i]) {
+			return nil, errs[i]
+		}
+		if errs[i] != nil {
+			point.Rollback()
+		} else if err := point.Commit(); err != nil {
+			return nil, fmt.Errorf("batch: %w", err)
+		}
+		if err := batched.batch.broken; err != nil {
+			return nil, fmt.Errorf("batch: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("batch: %w", err)
 	}
-	return false
+	return errs, nil
 }
 
-func printPlayers(players []Players) {
-	for i := range players {
-		fmt.Println(players[i])
+func (store *SQLiteStore) conn() sqlConn {
+	if store.batch != nil {
+		return store.batch.tx
 	}
+	return store.db
 }
 
-type Players struct {
-	num   int
+// begin starts a transaction, or a savepoint in the batch's transaction
+func (store *SQLiteStore) begin(ctx context.Context) (storeTx, error) {
+	if store.batch == nil {
+		tx, err := store.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	store.batch.savepoints++
+	point := &savepoint{Tx: store.batch.tx, batch: store.batch, ctx: ctx, name: fmt.Sprintf("call%d", store.batch.savepoints)}
+	if _, err := point.ExecContext(ctx, "SAVEPOINT "+point.name); err != nil {
+		return nil, err
+	}
+	return point, nil
+}
+
+// savepoint is a store call's part of a batch, rolling it back after it was released does nothing like a transaction
+type savepoint struct {
+	*sql.Tx
+	batch *sqliteBatch
+	ctx   context.Context
 	name  string
-	score int
+	done  bool
 }
 
-var (
-	path          = "./GameDatabase.db"
-	gameDB        = OpenDatabase(path)
-	SortedPlayers = SortScores(gameDB)
+func (point *savepoint) Commit() error {
+	if point.done {
+		return sql.ErrTxDone
+	}
+	point.done = true
+	_, err := point.Tx.ExecContext(point.ctx, "RELEASE "+point.name)
+	return err
+}
 
-	TopFive       []string
-	emptyTable    bool
-	LastHighScore int
-)
+func (point *savepoint) Rollback() error {
+	if point.done {
+		return sql.ErrTxDone
+	}
+	point.done = true
+	_, err := point.Tx.ExecContext(point.ctx, "ROLLBACK TO "+point.name)
+	if err == nil {
+		_, err = point.Tx.ExecContext(point.ctx, "RELEASE "+point.name)
+	}
+	if err != nil {
+		point.batch.broken = err
+	}
+	return err
+}
+
+// busyTimeout is how long SQLite waits for another copy of the game to let go of the database.
+// It is shorter than dbContext so a long wait ends as a busy error the score writer tries again, not a timeout.
+const busyTimeout = 1500 * time.Millisecond
+
+func OpenDatabase(dbfile string) (*sql.DB, error) {
+	if !sqliteAvailable {
+		return nil, errors.New("this game was built without cgo so it has no SQLite, pick -store json or -store memory")
+	}
+	// WAL lets every copy of the game read while one of them writes, and immediate transactions take
+	// the write lock when they begin so two copies never both start a transaction and then wait on each other
+	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate", dbfile, busyTimeout.Milliseconds())
+	database, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database %s: %w", dbfile, err)
+	}
+	return database, nil
+}
+
+// dbContext bounds a single store call so a stuck database cannot hang the game forever
+func dbContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 2*time.Second)
+}
+
+func (store *SQLiteStore) Migrate(ctx context.Context) error {
+	_, err := store.migrate(ctx)
+	return err
+}
 
-//func main(){
-//
-//	defer gameDB.Close()
-//
-//	if DbExists(path) == false {
-//		Create_tables(gameDB)
-//	}
-//
-//	//AddPlayerName(gameDB, "Khai")
-//
-//	SortedPlayers = SortScores(gameDB)
-//	printPlayers(SortedPlayers)
-//	fmt.Println(len(SortedPlayers))
-//	TopFive = GetTopFive(SortedPlayers)
-//	for i := range TopFive{
-//		fmt.Println(TopFive[i])
-//	}
-//}
-
-func GetPlayerNum() int {
-	var num int
-	statement := "SELECT IFNULL(MAX(player_num), 0) FROM players"
-	err := gameDB.QueryRow(statement).Scan(&num)
-	checkErr(err, "Get Last Entry from Player Num error")
-	return num
-}
-
-func UpdateScore(score int, id int) {
+// migrate backs up a database that has scores in it before changing its schema
+func (store *SQLiteStore) migrate(ctx context.Context) (int, error) {
+	current, err := schemaVersion(ctx, store.db)
+	if err != nil {
+		return 0, err
+	}
+	var tables int
+	err = store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name != 'schema_version'").Scan(&tables)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: %w", err)
+	}
+	if tables > 0 && current < migrations[len(migrations)-1].version {
+		if _, err := store.Backup(ctx, "migrate"); err != nil {
+			return 0, fmt.Errorf("backup before migrating: %w", err)
+		}
+	}
+	return Migrate(ctx, store.db, map[int]migrationStep{signedSchema: store.signUnsigned})
+}
+
+func (store *SQLiteStore) Close() error {
+	return store.db.Close()
+}
+
+func DbExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (store *SQLiteStore) UpdateScore(ctx context.Context, id int, score int) error {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("update score: %w", err)
+	}
+	defer tx.Rollback()
+	matched, err := store.matches(ctx, tx, id)
+	if err != nil {
+		return fmt.Errorf("update score: %w", err)
+	}
 	statement := "UPDATE players SET player_score = ? WHERE player_num = ?"
-	update_score, err := gameDB.Prepare(statement)
-	checkErr(err, "update score error")
-	execUpdate, err := update_score.Exec(score, id)
+	execUpdate, err := tx.ExecContext(ctx, statement, score, id)
+	if err != nil {
+		return fmt.Errorf("update score: %w", err)
+	}
 	check, err := execUpdate.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update score: %w", err)
+	}
 	if check == 0 {
-		log.Println("Score did not update")
-	} else {
-		log.Println("Score update Success")
+		return fmt.Errorf("update score: no player number %d", id)
+	}
+	if err := store.sign(ctx, tx, id, matched); err != nil {
+		return err
 	}
+	return tx.Commit()
 }
 
-func GetTopFive(players []Players) ([]string, int) {
+// topFive is the high score table for the game over screen, a header and then up to five runs
+func topFive(players []LeaderboardEntry) []string {
 	var playerScore []string
-	tempStr := fmt.Sprintf("|%-16s  %-6s ", "player", "score")
-	lastScore := 0
+	tempStr := fmt.Sprintf("|%-3s %-16s  %-6s ", "#", "player", "score")
 	playerScore = append(playerScore, tempStr)
-	if len(players) <= 5 {
-		for i := range players {
-			tempStr = fmt.Sprintf("|%-16s  %-6d ", players[i].name, players[i].score)
-			playerScore = append(playerScore, tempStr)
-			lastScore = players[i].score
-		}
-	} else {
-		for i := 0; i < 5; i++ {
-			tempStr = fmt.Sprintf("|%-16s  %-6d", players[i].name, players[i].score)
-			playerScore = append(playerScore, tempStr)
-			lastScore = players[i].score
+	for i := 0; i < len(players) && i < 5; i++ {
+		tempStr = fmt.Sprintf("|%-3d %-16s  %-6d", players[i].rank, players[i].name, players[i].score)
+		if players[i].tampered {
+			tempStr += " !"
 		}
+		playerScore = append(playerScore, tempStr)
 	}
-	return playerScore, lastScore
+	return playerScore
 }
 
-func AddPlayerName(playername string) {
-	statement := "INSERT INTO players (player_name, player_score) VALUES (?, ?)"
-	prepped_statement, err := gameDB.Prepare(statement)
-	checkErr(err, "add player prep statement error")
-	//playerKey := 1001
-
-	_, err = prepped_statement.Exec(playername, 0)
-	checkErr(err, "add player exec error")
+// AddPlayer stores a new run for the profile with a score of zero and returns its player number
+func (store *SQLiteStore) AddPlayer(ctx context.Context, profile Profile) (int, error) {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("add player %q: %w", profile.name, err)
+	}
+	defer tx.Rollback()
+	statement := "INSERT INTO players (player_name, player_score, profile_id) VALUES (?, ?, ?)"
+	result, err := tx.ExecContext(ctx, statement, profile.name, 0, profile.id)
+	if err != nil {
+		return 0, fmt.Errorf("add player %q: %w", profile.name, err)
+	}
+	num, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("add player %q: %w", profile.name, err)
+	}
+	if err := store.sign(ctx, tx, int(num), true); err != nil {
+		return 0, err
+	}
+	return int(num), tx.Commit()
 }
 
-func SortScores(db *sql.DB) []Players {
-	var data []Players
-	rows, err := db.Query("SELECT * FROM players ORDER BY player_score desc")
-	checkErr(err, "sort scores query error")
+func (store *SQLiteStore) LoadSettings(ctx context.Context, profileID int) (map[string]string, error) {
+	settings := make(map[string]string)
+	rows, err := store.conn().QueryContext(ctx, "SELECT setting, value FROM profile_settings WHERE profile_id = ?", profileID)
+	if err != nil {
+		return nil, fmt.Errorf("load settings for profile %d: %w", profileID, err)
+	}
+	defer rows.Close()
 	for rows.Next() {
-		var num int
-		var name string
-		var score int
-		err2 := rows.Scan(&num, &name, &score)
-		checkErr(err2, "sort scores query scan error")
-		dataElement := Players{
-			num:   num,
-			name:  name,
-			score: score,
+		var setting string
+		var value string
+		if err := rows.Scan(&setting, &value); err != nil {
+			return nil, fmt.Errorf("load settings for profile %d: %w", profileID, err)
 		}
-		data = append(data, dataElement)
+		settings[setting] = value
 	}
-	return data
+	return settings, rows.Err()
 }
 
-func isTableEmpty(db *sql.DB) bool {
-	_, table_check := db.Query("select * from players;")
-
-	if table_check == nil {
-		return false
+func (store *SQLiteStore) SaveSettings(ctx context.Context, profileID int, settings map[string]string) error {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("save settings for profile %d: %w", profileID, err)
+	}
+	defer tx.Rollback()
+	statement := "INSERT OR REPLACE INTO profile_settings (profile_id, setting, value) VALUES (?, ?, ?)"
+	for setting, value := range settings {
+		if _, err := tx.ExecContext(ctx, statement, profileID, setting, value); err != nil {
+			return fmt.Errorf("save settings for profile %d: %w", profileID, err)
+		}
 	}
-	return true
+	return tx.Commit()
+}
+
+// unavailableStore stands in when the database cannot be opened so the game still runs without scores
+type unavailableStore struct {
+	err error
+}
+
+func (store unavailableStore) Migrate(ctx context.Context) error {
+	return store.err
+}
+
+func (store unavailableStore) Close() error {
+	return nil
+}
+
+func (store unavailableStore) FindProfile(ctx context.Context, playername string) (Profile, bool, error) {
+	return Profile{}, false, store.err
+}
+
+func (store unavailableStore) EnsureProfile(ctx context.Context, playername string) (Profile, error) {
+	return Profile{name: playername}, store.err
+}
+
+func (store unavailableStore) AddPlayer(ctx context.Context, profile Profile) (int, error) {
+	return 0, store.err
+}
+
+func (store unavailableStore) UpdateScore(ctx context.Context, id int, score int) error {
+	return store.err
+}
+
+func (store unavailableStore) Leaderboard(ctx context.Context, query LeaderboardQuery) ([]LeaderboardEntry, int, error) {
+	return nil, 0, store.err
+}
+
+func (store unavailableStore) LeaderboardRank(ctx context.Context, query LeaderboardQuery, playerNum int) (LeaderboardEntry, bool, error) {
+	return LeaderboardEntry{}, false, store.err
+}
+
+func (store unavailableStore) LoadSettings(ctx context.Context, profileID int) (map[string]string, error) {
+	return nil, store.err
+}
+
+func (store unavailableStore) SaveSettings(ctx context.Context, profileID int, settings map[string]string) error {
+	return store.err
+}
+
+func (store unavailableStore) Unlock(ctx context.Context, profileID int, unlock string) error {
+	return store.err
+}
+
+func (store unavailableStore) StartSession(ctx context.Context, session *Session) error {
+	return store.err
+}
+
+func (store unavailableStore) FinishSession(ctx context.Context, session Session) error {
+	return store.err
+}
+
+func (store unavailableStore) CheckpointSession(ctx context.Context, session Session) error {
+	return store.err
+}
+
+func (store unavailableStore) RecentSessions(ctx context.Context, profileID int, limit int) ([]Session, error) {
+	return nil, store.err
+}
+
+func (store unavailableStore) PersonalBests(ctx context.Context, profileID int) (PersonalBests, error) {
+	return PersonalBests{}, store.err
+}
+
+func (store unavailableStore) SubmitReplay(ctx context.Context, playerNum int, replay Replay) (string, error) {
+	return "", store.err
+}
+
+func (store unavailableStore) SaveReplay(ctx context.Context, playerNum int, replay Replay, reason string) error {
+	return store.err
+}
+
+func (store unavailableStore) ReviewQueue(ctx context.Context) ([]Review, error) {
+	return nil, store.err
+}
+
+func (store unavailableStore) ReviewRun(ctx context.Context, playerNum int, status string) error {
+	return store.err
+}
+
+func (store unavailableStore) QueueSubmission(ctx context.Context, submission Submission) error {
+	return store.err
+}
+
+func (store unavailableStore) DueSubmissions(ctx context.Context, now time.Time) ([]Submission, error) {
+	return nil, store.err
+}
+
+func (store unavailableStore) RetrySubmission(ctx context.Context, submission Submission) error {
+	return store.err
+}
+
+func (store unavailableStore) RemoveSubmission(ctx context.Context, key string) error {
+	return store.err
+}
+
+func (store unavailableStore) SubmissionAnswer(ctx context.Context, key string) (string, bool, error) {
+	return "", false, store.err
+}
+
+func (store unavailableStore) SaveSubmissionAnswer(ctx context.Context, key string, answer string) error {
+	return store.err
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	hammerRuns  = 30
+	hammerNames = 3
+)
+
+// hammer plays runs through the store the way the game does, a busy database is tried again as the writer would
+func hammer(store ScoreStore, who string) error {
+	for i := 0; i < hammerRuns; i++ {
+		var profile Profile
+		var playerNum int
+		session := Session{startedAt: time.Now(), difficulty: "Normal", mode: ModeSolo, version: GameVersion}
+		steps := []func(ctx context.Context) error{
+			func(ctx context.Context) (err error) {
+				profile, err = store.EnsureProfile(ctx, fmt.Sprintf("%s-%d", who, i%hammerNames))
+				return err
+			},
+			func(ctx context.Context) (err error) {
+				playerNum, err = store.AddPlayer(ctx, profile)
+				session.profileID, session.playerNum = profile.id, playerNum
+				return err
+			},
+			func(ctx context.Context) error {
+				if session.id != 0 {
+					return nil
+				}
+				return store.StartSession(ctx, &session)
+			},
+			func(ctx context.Context) error {
+				session.score = i * 10
+				return store.UpdateScore(ctx, playerNum, session.score)
+			},
+			func(ctx context.Context) error {
+				return store.CheckpointSession(ctx, session)
+			},
+			func(ctx context.Context) error {
+				session.endedAt, session.outcome = time.Now(), OutcomeLost
+				return store.FinishSession(ctx, session)
+			},
+			func(ctx context.Context) error {
+				return store.SaveSettings(ctx, profile.id, map[string]string{"volume": fmt.Sprint(i)})
+			},
+		}
+		for _, step := range steps {
+			if err := retryBusy(step); err != nil {
+				return fmt.Errorf("%s run %d: %w", who, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func openHammerStore(t *testing.T, file string) *SQLiteStore {
+	t.Helper()
+	if !sqliteAvailable {
+		t.Skip("built without cgo, there is no SQLite")
+	}
+	store, err := OpenStore(BackendSQLite, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := retryBusy(store.Migrate); err != nil {
+		t.Fatal(err)
+	}
+	return store.(*SQLiteStore)
+}
+
+// checkHammered makes sure every run from every writer is there, whole and signed
+func checkHammered(t *testing.T, store *SQLiteStore, writers int) {
+	t.Helper()
+	ctx := context.Background()
+	counts := dump(t, store.db, "SELECT (SELECT COUNT(*) FROM players), (SELECT COUNT(*) FROM sessions WHERE outcome = 'lost'), "+
+		"(SELECT COUNT(*) FROM profiles), (SELECT COUNT(*) FROM schema_version)")
+	want := fmt.Sprintf("%d|%d|%d|%d", writers*hammerRuns, writers*hammerRuns, writers*hammerNames, len(migrations))
+	if counts != want {
+		t.Fatalf("players, finished sessions, profiles and versions %s, want %s", counts, want)
+	}
+	if problems, err := store.Check(ctx); err != nil || len(problems) != 0 {
+		t.Fatalf("check: %v %v", problems, err)
+	}
+	if problems, err := store.CheckSignatures(ctx); err != nil || len(problems) != 0 {
+		t.Fatalf("%d runs do not match their signatures: %v", len(problems), err)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	store := openHammerStore(t, filepath.Join(t.TempDir(), "scores.db"))
+	const writers = 6
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- hammer(store, fmt.Sprint("goroutine", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	checkHammered(t, store, writers)
+}
+
+// TestHammerProcess is one copy of the game for TestSeveralProcesses, it does nothing when run on its own
+func TestHammerProcess(t *testing.T) {
+	file := os.Getenv("PUPPEROOO_HAMMER_FILE")
+	if file == "" {
+		t.Skip("only run by TestSeveralProcesses")
+	}
+	store := openHammerStore(t, file)
+	if err := hammer(store, os.Getenv("PUPPEROOO_HAMMER_NAME")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSeveralProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts several copies of the test binary")
+	}
+	file := filepath.Join(t.TempDir(), "scores.db")
+	const processes = 4
+	var wg sync.WaitGroup
+	// they all start on a database that is not there yet, so they race to migrate it as well
+	for i := 0; i < processes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHammerProcess$", "-test.count=1")
+			cmd.Env = append(os.Environ(), "PUPPEROOO_HAMMER_FILE="+file, fmt.Sprint("PUPPEROOO_HAMMER_NAME=process", i))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("process %d: %v\n%s", i, err, out)
+			}
+		}(i)
+	}
+	wg.Wait()
+	checkHammered(t, openHammerStore(t, file), processes)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RunRecord is one run as it is exported, with its session when the run has one.
+// The signature and replay let the machine it is imported on check it was not changed on the way.
+type RunRecord struct {
+	Player      string `json:"player"`
+	Score       int    `json:"score"`
+	StartedAt   string `json:"started_at,omitempty"`
+	EndedAt     string `json:"ended_at,omitempty"`
+	DurationMs  int64  `json:"duration_ms,omitempty"`
+	Level       int    `json:"level,omitempty"`
+	LivesLeft   int    `json:"lives_left,omitempty"`
+	Outcome     string `json:"outcome,omitempty"`
+	Difficulty  string `json:"difficulty,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	Seed        int64  `json:"seed,omitempty"`
+	GameVersion string `json:"game_version,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	Replay      string `json:"replay,omitempty"`
+}
+
+var runColumns = []string{"player", "score", "started_at", "ended_at", "duration_ms", "level", "lives_left",
+	"outcome", "difficulty", "mode", "seed", "game_version", "signature", "replay"}
+
+// replayedSession is what the record's replay has to play out to
+func (record RunRecord) replayedSession() Session {
+	return Session{ticks: ticksIn(record.DurationMs), level: record.Level, livesLeft: record.LivesLeft, outcome: record.Outcome,
+		difficulty: record.Difficulty, mode: record.Mode, seed: record.Seed}
+}
+
+func (store *SQLiteStore) ExportRuns(ctx context.Context) ([]RunRecord, error) {
+	statement := "SELECT players.player_name, players.player_score, IFNULL(sessions.started_at, ''), IFNULL(sessions.ended_at, ''), " +
+		"IFNULL(sessions.duration_ms, 0), IFNULL(sessions.highest_level, 0), IFNULL(sessions.lives_left, 0), IFNULL(sessions.outcome, ''), " +
+		"IFNULL(sessions.difficulty, ''), IFNULL(sessions.mode, ''), IFNULL(sessions.seed, 0), IFNULL(sessions.game_version, ''), " +
+		"IFNULL(players.signature, ''), IFNULL(replays.replay, '') FROM players " +
+		"LEFT JOIN sessions ON sessions.player_num = players.player_num LEFT JOIN replays ON replays.player_num = players.player_num " +
+		"ORDER BY players.player_num"
+	rows, err := store.conn().QueryContext(ctx, statement)
+	if err != nil {
+		return nil, fmt.Errorf("export runs: %w", err)
+	}
+	defer rows.Close()
+	var records []RunRecord
+	for rows.Next() {
+		var record RunRecord
+		err := rows.Scan(&record.Player, &record.Score, &record.StartedAt, &record.EndedAt, &record.DurationMs, &record.Level,
+			&record.LivesLeft, &record.Outcome, &record.Difficulty, &record.Mode, &record.Seed, &record.GameVersion,
+			&record.Signature, &record.Replay)
+		if err != nil {
+			return nil, fmt.Errorf("export runs: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// ImportRuns adds the runs that are not already in the database. A run is a duplicate when the player (in any case),
+// score, start time and seed all match. A dry run does all the work and then rolls it back so the counts are exact.
+// A run is trusted like one played here only when its signature is this machine's and its replay plays back to it,
+// otherwise it goes into the review queue, and unsigned when the signature is not this machine's.
+func (store *SQLiteStore) ImportRuns(ctx context.Context, records []RunRecord, dryRun bool) (int, int, error) {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("import runs: %w", err)
+	}
+	defer tx.Rollback()
+
+	imported, duplicates := 0, 0
+	for i, record := range records {
+		record.Player = strings.TrimSpace(record.Player)
+		if record.Player == "" {
+			return 0, 0, fmt.Errorf("import runs: record %d has no player", i+1)
+		}
+		if record.StartedAt != "" && record.Difficulty == "" {
+			record.Difficulty = difficultyNamed("").name
+		}
+		if record.StartedAt != "" && record.Mode == "" {
+			record.Mode = ModeSolo
+		}
+		var matches int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM players LEFT JOIN sessions ON sessions.player_num = players.player_num "+
+			"WHERE players.player_name = ? COLLATE NOCASE AND players.player_score = ? "+
+			"AND IFNULL(sessions.started_at, '') = ? AND IFNULL(sessions.seed, 0) = ?",
+			record.Player, record.Score, record.StartedAt, record.Seed).Scan(&matches)
+		if err != nil {
+			return 0, 0, fmt.Errorf("import runs: %w", err)
+		}
+		if matches > 0 {
+			duplicates++
+			continue
+		}
+		playerNum, err := importRun(ctx, tx, record)
+		if err == nil {
+			err = store.vetImport(ctx, tx, playerNum, record)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("import runs: record %d (%s): %w", i+1, record.Player, err)
+		}
+		imported++
+	}
+	if dryRun {
+		return imported, duplicates, nil
+	}
+	return imported, duplicates, tx.Commit()
+}
+
+// vetImport keeps an imported run's signature when this machine's key made it and checks its replay,
+// a run that fails either goes into the review queue
+func (store *SQLiteStore) vetImport(ctx context.Context, tx sqlConn, playerNum int, record RunRecord) error {
+	var reasons []string
+	rows, err := tx.QueryContext(ctx, signedRecordSQL+" WHERE players.player_num = ?", playerNum)
+	if err != nil {
+		return err
+	}
+	signed, err := scanSignedRows(rows)
+	if err != nil {
+		return err
+	}
+	if len(signed) == 1 && record.Signature != "" && store.key.verify(signed[0].record, record.Signature) {
+		if _, err := tx.ExecContext(ctx, "UPDATE players SET signature = ? WHERE player_num = ?", record.Signature, playerNum); err != nil {
+			return err
+		}
+	} else {
+		reasons = append(reasons, "imported without this machine's signature")
+	}
+
+	if record.Replay == "" {
+		reasons = append(reasons, "imported without a replay")
+	} else if replay, err := decodeReplay(record.Replay); err != nil {
+		reasons = append(reasons, "the imported replay cannot be read: "+err.Error())
+	} else {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO replays (player_num, replay) VALUES (?, ?)", playerNum, record.Replay); err != nil {
+			return err
+		}
+		if mismatch, err := checkReplay(ctx, replay, record.Score, record.replayedSession()); err != nil {
+			return err
+		} else if mismatch != "" {
+			reasons = append(reasons, mismatch)
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO review_queue (player_num, reason, status) VALUES (?, ?, ?)",
+		playerNum, strings.Join(reasons, "; "), ReviewPending)
+	return err
+}
+
+// importRun adds one run and its session and returns the run's player number
+func importRun(ctx context.Context, tx sqlConn, record RunRecord) (int, error) {
+	_, err := tx.ExecContext(ctx, "INSERT INTO profiles (name) VALUES (?) ON CONFLICT(name) DO NOTHING", record.Player)
+	if err != nil {
+		return 0, err
+	}
+	var profileID int
+	if err := tx.QueryRowContext(ctx, "SELECT profile_id FROM profiles WHERE name = ?", record.Player).Scan(&profileID); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, "INSERT INTO players (player_name, player_score, profile_id) VALUES (?, ?, ?)",
+		record.Player, record.Score, profileID)
+	if err != nil {
+		return 0, err
+	}
+	playerNum, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if record.StartedAt == "" { // a run from before sessions were recorded
+		return int(playerNum), nil
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO sessions (profile_id, player_num, started_at, ended_at, duration_ms, final_score, "+
+		"highest_level, lives_left, outcome, difficulty, mode, seed, game_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		profileID, playerNum, record.StartedAt, nullIfEmpty(record.EndedAt), record.DurationMs, record.Score,
+		record.Level, record.LivesLeft, nullIfEmpty(record.Outcome), record.Difficulty, record.Mode, record.Seed, record.GameVersion)
+	return int(playerNum), err
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// exportFormat picks csv or json, from the flag if it was given and otherwise from the file name
+func exportFormat(format string, filename string) (string, error) {
+	if format == "" {
+		format = "json"
+		if strings.EqualFold(filepath.Ext(filename), ".csv") {
+			format = "csv"
+		}
+	}
+	if format != "csv" && format != "json" {
+		return "", fmt.Errorf("unknown format %q, use csv or json", format)
+	}
+	return format, nil
+}
+
+func writeRuns(out io.Writer, format string, records []RunRecord) error {
+	if format == "json" {
+		if records == nil {
+			records = []RunRecord{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+
+	writer := csv.NewWriter(out)
+	writer.Write(runColumns)
+	for _, record := range records {
+		writer.Write([]string{record.Player, strconv.Itoa(record.Score), record.StartedAt, record.EndedAt,
+			strconv.FormatInt(record.DurationMs, 10), strconv.Itoa(record.Level), strconv.Itoa(record.LivesLeft),
+			record.Outcome, record.Difficulty, record.Mode, strconv.FormatInt(record.Seed, 10), record.GameVersion,
+			record.Signature, record.Replay})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func readRuns(in io.Reader, format string) ([]RunRecord, error) {
+	var records []RunRecord
+	if format == "json" {
+		if err := json.NewDecoder(in).Decode(&records); err != nil {
+			return nil, fmt.Errorf("read runs: %w", err)
+		}
+		return records, nil
+	}
+
+	rows, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read runs: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	// columns are found by their heading so a file with columns missing or moved around still reads
+	column := make(map[string]int)
+	for i, heading := range rows[0] {
+		column[strings.TrimSpace(heading)] = i
+	}
+	if _, ok := column["player"]; !ok {
+		return nil, fmt.Errorf("read runs: the csv has no player column")
+	}
+	for line, row := range rows[1:] {
+		field := func(name string) string {
+			if i, ok := column[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		number := func(name string) int64 {
+			if err != nil || field(name) == "" {
+				return 0
+			}
+			var value int64
+			value, err = strconv.ParseInt(field(name), 10, 64)
+			return value
+		}
+		record := RunRecord{
+			Player:      field("player"),
+			Score:       int(number("score")),
+			StartedAt:   field("started_at"),
+			EndedAt:     field("ended_at"),
+			DurationMs:  number("duration_ms"),
+			Level:       int(number("level")),
+			LivesLeft:   int(number("lives_left")),
+			Outcome:     field("outcome"),
+			Difficulty:  field("difficulty"),
+			Mode:        field("mode"),
+			Seed:        number("seed"),
+			GameVersion: field("game_version"),
+			Signature:   field("signature"),
+			Replay:      field("replay"),
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read runs: line %d: %w", line+2, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exportedRuns plays two runs on a store with its own key and exports them
+func exportedRuns(t *testing.T) ([]RunRecord, ScoreKey) {
+	t.Helper()
+	store := migratedStore(t)
+	for seed := int64(1); seed <= 2; seed++ {
+		replay, session := playRun(t, seed, 3000)
+		saveRun(t, store, "Huy", replay, session)
+	}
+	records, err := store.ExportRuns(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Signature == "" || records[0].Replay == "" {
+		t.Fatalf("exported %+v", records)
+	}
+	return records, store.key
+}
+
+func importInto(t *testing.T, store *SQLiteStore, records []RunRecord) []Review {
+	t.Helper()
+	ctx := context.Background()
+	if imported, duplicates, err := store.ImportRuns(ctx, records, false); err != nil || imported != len(records) || duplicates != 0 {
+		t.Fatalf("imported %d with %d duplicates: %v", imported, duplicates, err)
+	}
+	reviews, err := store.ReviewQueue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reviews
+}
+
+func TestImportKeepsSignaturesFromTheSameKey(t *testing.T) {
+	records, key := exportedRuns(t)
+	store := migratedStore(t)
+	store.key = key
+	if reviews := importInto(t, store, records); len(reviews) != 0 {
+		t.Fatalf("runs signed with this key and matching their replays were held: %v", reviews)
+	}
+	if problems, err := store.CheckSignatures(context.Background()); err != nil || len(problems) != 0 {
+		t.Fatalf("imported signatures: %v %v", problems, err)
+	}
+	again, err := store.ExportRuns(context.Background())
+	if err != nil || !reflect.DeepEqual(again, records) {
+		t.Fatalf("exported again:\n%+v\nwant:\n%+v\n%v", again, records, err)
+	}
+}
+
+func TestImportHoldsRunsItCannotTrust(t *testing.T) {
+	records, key := exportedRuns(t)
+	ctx := context.Background()
+
+	// another machine's key signs nothing here, the runs come in unsigned and wait for review
+	other := migratedStore(t)
+	reviews := importInto(t, other, records)
+	if len(reviews) != 2 || !strings.Contains(reviews[0].reason, "without this machine's signature") {
+		t.Fatalf("reviews %v", reviews)
+	}
+	if got := dump(t, other.db, "SELECT COUNT(*) FROM players WHERE signature IS NULL"); got != "2" {
+		t.Fatalf("%s runs were left unsigned, want 2", got)
+	}
+	if entries, _, err := other.Leaderboard(ctx, LeaderboardQuery{window: WindowAllTime, limit: 10}); err != nil || len(entries) != 0 {
+		t.Fatalf("runs waiting for review are on the board: %v %v", entries, err)
+	}
+
+	// a score raised in the file breaks its signature and its replay
+	store := migratedStore(t)
+	store.key = key
+	records[0].Score += 5000
+	records[1].Replay = ""
+	reviews = importInto(t, store, records)
+	if len(reviews) != 2 {
+		t.Fatalf("reviews %v", reviews)
+	}
+	if reason := reviews[0].reason; !strings.Contains(reason, "without this machine's signature") || !strings.Contains(reason, "replay scores") {
+		t.Fatalf("the changed score was held for %q", reason)
+	}
+	if reason := reviews[1].reason; reason != "imported without a replay" {
+		t.Fatalf("the run without a replay was held for %q", reason)
+	}
+}
+
+func TestRunsCSV(t *testing.T) {
+	records, key := exportedRuns(t)
+	var out bytes.Buffer
+	if err := writeRuns(&out, "csv", records); err != nil {
+		t.Fatal(err)
+	}
+	csv := out.String()
+	read, err := readRuns(strings.NewReader(csv), "csv")
+	if err != nil || len(read) != len(records) {
+		t.Fatalf("read back %d runs: %v", len(read), err)
+	}
+	for i := range read {
+		if read[i].Replay = records[i].Replay; !reflect.DeepEqual(read[i], records[i]) {
+			t.Fatalf("run %d read back as %+v", i, read[i])
+		}
+	}
+
+	// the replays come back without the spaces around them, which does not stop them playing
+	read, _ = readRuns(strings.NewReader(csv), "csv")
+	store := migratedStore(t)
+	store.key = key
+	if reviews := importInto(t, store, read); len(reviews) != 0 {
+		t.Fatalf("runs read from csv were held: %v", reviews)
+	}
+}
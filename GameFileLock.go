@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes a lock on path that the system lets go of when the process ends, so a crash never leaves it
+// held. The lock is the process's until release is called, another copy of the game gets errFileLocked.
+func lockFile(path string) (release func() error, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errFileLocked
+		}
+		return nil, err
+	}
+	return file.Close, nil // closing the file lets go of the lock
+}
@@ -0,0 +1,22 @@
+package main
+
+import "syscall"
+
+// errorSharingViolation is what Windows says when another handle has the file open without sharing it
+const errorSharingViolation = syscall.Errno(32)
+
+// lockFile opens path without sharing it, Windows closes the handle and so lets go of the lock when the process ends
+func lockFile(path string) (release func() error, err error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errorSharingViolation {
+		return nil, errFileLocked
+	} else if err != nil {
+		return nil, err
+	}
+	return func() error { return syscall.CloseHandle(handle) }, nil
+}
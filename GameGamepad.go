@@ -0,0 +1,193 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"math"
+	"runtime"
+	"sort"
+)
+
+// GamepadLayout maps the raw axes and buttons GLFW reports for an xbox style pad onto actions
+type GamepadLayout struct {
+	leftX      int
+	leftY      int
+	rightX     int
+	rightY     int
+	deadZone   float64
+	aimZone    float64
+	buttons    map[Action][]ebiten.GamepadButton
+	padButtons int // the buttons of the pad itself, GLFW adds a hat after them as four more - up, right, down, left
+}
+
+func defaultGamepadLayout() GamepadLayout {
+	layout := GamepadLayout{
+		leftX:    0,
+		leftY:    1,
+		rightX:   2,
+		rightY:   3,
+		deadZone: 0.35,
+		aimZone:  0.6,
+		buttons: map[Action][]ebiten.GamepadButton{
+			ActionShootDown:  {ebiten.GamepadButton0}, // A
+			ActionShootRight: {ebiten.GamepadButton1}, // B
+			ActionShootLeft:  {ebiten.GamepadButton2}, // X
+			ActionShootUp:    {ebiten.GamepadButton3}, // Y
+			ActionDash:       {ebiten.GamepadButton4, ebiten.GamepadButton5},
+			ActionBack:       {ebiten.GamepadButton1},
+			ActionConfirm:    {ebiten.GamepadButton0},
+			ActionPause:      {ebiten.GamepadButton7}, // Start
+		},
+		padButtons: 10,
+	}
+	if runtime.GOOS == "linux" { // the left trigger sits between the two sticks on linux, and the guide button counts
+		layout.rightX = 3
+		layout.rightY = 4
+		layout.padButtons = 11
+	}
+	return layout
+}
+
+// gamepadInput tracks connected pads as they are plugged in and out and reads all of them,
+// or in co-op only the first one, the second is player two's
+type gamepadInput struct {
+	layout GamepadLayout
+	pads   map[ebiten.GamepadID]string
+	events []string
+	split  bool
+}
+
+func newGamepadInput() *gamepadInput {
+	return &gamepadInput{
+		layout: defaultGamepadLayout(),
+		pads:   map[ebiten.GamepadID]string{},
+	}
+}
+
+func (gamepads *gamepadInput) connected() bool {
+	return len(gamepads.pads) > 0
+}
+
+// takeEvents returns the connect and disconnect messages since the last call
+func (gamepads *gamepadInput) takeEvents() []string {
+	events := gamepads.events
+	gamepads.events = nil
+	return events
+}
+
+func (gamepads *gamepadInput) refresh() {
+	for _, id := range inpututil.JustConnectedGamepadIDs() {
+		gamepads.pads[id] = ebiten.GamepadName(id)
+		gamepads.events = append(gamepads.events, "Gamepad connected: "+gamepads.pads[id])
+	}
+	for id, name := range gamepads.pads {
+		if inpututil.IsGamepadJustDisconnected(id) {
+			delete(gamepads.pads, id)
+			gamepads.events = append(gamepads.events, "Gamepad disconnected: "+name)
+		}
+	}
+}
+
+// order is the connected pads, the one connected first first
+func (gamepads *gamepadInput) order() []ebiten.GamepadID {
+	var ids []ebiten.GamepadID
+	for id := range gamepads.pads {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (gamepads *gamepadInput) Poll() InputFrame {
+	gamepads.refresh()
+
+	var frame InputFrame
+	ids := gamepads.order()
+	if gamepads.split && len(ids) > 1 {
+		ids = ids[:1]
+	}
+	for _, id := range ids {
+		frame.merge(gamepads.pollPad(id))
+	}
+	return frame
+}
+
+// partnerGamepad is player two's pad in co-op, the second one connected
+type partnerGamepad struct {
+	gamepads *gamepadInput
+}
+
+func (partner partnerGamepad) Poll() InputFrame {
+	if ids := partner.gamepads.order(); partner.gamepads.split && len(ids) > 1 {
+		return partner.gamepads.pollPad(ids[1])
+	}
+	return InputFrame{}
+}
+
+func (gamepads *gamepadInput) pollPad(id ebiten.GamepadID) InputFrame {
+	var frame InputFrame
+	layout := gamepads.layout
+	axisNum := ebiten.GamepadAxisNum(id)
+	readAxis := func(axis int) float64 {
+		if axis >= axisNum {
+			return 0
+		}
+		return ebiten.GamepadAxis(id, axis)
+	}
+
+	// left stick moves
+	leftX, leftY := readAxis(layout.leftX), readAxis(layout.leftY)
+	if leftX < -layout.deadZone {
+		frame.Set(ActionLeft)
+	} else if leftX > layout.deadZone {
+		frame.Set(ActionRight)
+	}
+	if leftY < -layout.deadZone {
+		frame.Set(ActionUp)
+	} else if leftY > layout.deadZone {
+		frame.Set(ActionDown)
+	}
+
+	// right stick throws along whichever axis is pushed further
+	rightX, rightY := readAxis(layout.rightX), readAxis(layout.rightY)
+	if math.Max(math.Abs(rightX), math.Abs(rightY)) > layout.aimZone {
+		if math.Abs(rightX) > math.Abs(rightY) {
+			if rightX < 0 {
+				frame.Set(ActionShootLeft)
+			} else {
+				frame.Set(ActionShootRight)
+			}
+		} else if rightY < 0 {
+			frame.Set(ActionShootUp)
+		} else {
+			frame.Set(ActionShootDown)
+		}
+	}
+
+	for action, buttons := range layout.buttons {
+		for _, button := range buttons {
+			if ebiten.IsGamepadButtonPressed(id, button) {
+				frame.Set(action)
+			}
+		}
+	}
+
+	if first, ok := layout.hat(ebiten.GamepadButtonNum(id)); ok {
+		dpad := [4]Action{ActionUp, ActionRight, ActionDown, ActionLeft}
+		for i, action := range dpad {
+			if ebiten.IsGamepadButtonPressed(id, first+ebiten.GamepadButton(i)) {
+				frame.Set(action)
+			}
+		}
+	}
+	return frame
+}
+
+// hat finds the d-pad in a pad's buttons. It is only there when GLFW reported four buttons past the pad's own,
+// on a pad without one the last four buttons are the bumpers, Back and Start and must not move the dog.
+func (layout GamepadLayout) hat(buttonNum int) (first ebiten.GamepadButton, ok bool) {
+	if buttonNum < layout.padButtons+4 {
+		return 0, false
+	}
+	return ebiten.GamepadButton(buttonNum - 4), true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"testing"
+)
+
+func TestGamepadHat(t *testing.T) {
+	tests := []struct {
+		name       string
+		padButtons int
+		buttonNum  int
+		wantFirst  ebiten.GamepadButton
+		wantOK     bool
+	}{
+		{"xinput pad with its hat", 10, 14, 10, true},
+		{"linux xpad with its hat", 11, 15, 11, true},
+		{"linux pad without a hat keeps its bumpers and Start", 11, 11, 0, false},
+		{"a pad with fewer buttons than a hat needs", 10, 3, 0, false},
+		{"no buttons at all", 10, 0, 0, false},
+		{"two hats, the last one is the d-pad", 10, 18, 14, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layout := defaultGamepadLayout()
+			layout.padButtons = test.padButtons
+			first, ok := layout.hat(test.buttonNum)
+			if first != test.wantFirst || ok != test.wantOK {
+				t.Fatalf("hat(%d) = %d, %t, want %d, %t", test.buttonNum, first, ok, test.wantFirst, test.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/colornames"
+	"image/color"
+	"strings"
+)
+
+const highScoreRows = 15
+
+func openHighScores(game *Game) {
+	if game.scores.query.limit == 0 {
+		game.scores = newLeaderboardView(highScoreRows)
+	}
+	game.scores.query.offset = 0
+	refreshHighScores(game)
+	game.scoresOpen = true
+}
+
+func refreshHighScores(game *Game) {
+	refreshLater(game, &game.scores, 0)
+}
+
+func highScoreScreen(game *Game) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF2) || inpututil.IsKeyJustPressed(ebiten.KeyTab) || game.justPressed(ActionBack) {
+		game.scoresOpen = false
+		return
+	}
+
+	view := &game.scores
+	offset := view.query.offset
+	if game.justPressed(ActionUp) {
+		view.scroll(-1)
+	} else if game.justPressed(ActionDown) {
+		view.scroll(1)
+	} else if game.justPressed(ActionLeft) || inpututil.IsKeyJustPressed(ebiten.KeyPageUp) {
+		view.scroll(-highScoreRows)
+	} else if game.justPressed(ActionRight) || inpututil.IsKeyJustPressed(ebiten.KeyPageDown) {
+		view.scroll(highScoreRows)
+	}
+	changed := view.query.offset != offset
+
+	// a new filter makes a new board so it starts again from the top
+	if game.justPressed(ActionShootUp) {
+		view.query.window = (view.query.window + 1) % numWindows
+		view.query.offset, changed = 0, true
+	} else if game.justPressed(ActionShootLeft) {
+		choices := []string{""}
+		for _, difficulty := range difficulties {
+			choices = append(choices, difficulty.name)
+		}
+		view.query.difficulty = nextFilter(choices, view.query.difficulty)
+		view.query.offset, changed = 0, true
+	} else if game.justPressed(ActionShootDown) {
+		view.query.mode = nextFilter(append([]string{""}, modeNames...), view.query.mode)
+		view.query.offset, changed = 0, true
+	}
+	if changed {
+		refreshHighScores(game)
+	}
+}
+
+func (game Game) drawHighScores(screen *ebiten.Image) {
+	smallFont := makeFont(14, 72)
+	view := game.scores
+	text.Draw(screen, "High Scores", makeFont(30, 72), 50, 260, colornames.Tomato)
+	filters := fmt.Sprintf("%s time: %s      %s difficulty: %s      %s mode: %s",
+		game.bindings.describe(ActionShootUp), windowNames[view.query.window],
+		game.bindings.describe(ActionShootLeft), filterName(view.query.difficulty),
+		game.bindings.describe(ActionShootDown), filterName(view.query.mode))
+	text.Draw(screen, filters, smallFont, 50, 290, colornames.Yellow)
+
+	columns := []int{50, 110, 300, 390, 460, 560, 640}
+	headings := []string{"#", "player", "score", "level", "difficulty", "mode", "date"}
+	for i := range headings {
+		text.Draw(screen, headings[i], smallFont, columns[i], 320, colornames.Tomato)
+	}
+	if game.scoresErr != nil {
+		text.Draw(screen, "Scores unavailable", makeFont(20, 72), 50, 350, colornames.Tomato)
+	} else if len(view.entries) == 0 {
+		text.Draw(screen, "no scores yet", smallFont, 50, 345, color.White)
+	}
+
+	me := strings.TrimSpace(game.infoBar.playerName)
+	for row, entry := range view.entries {
+		date, level := "-", "-"
+		if !entry.playedAt.IsZero() {
+			date = entry.playedAt.Local().Format("2006-01-02 15:04")
+		}
+		if entry.level > 0 {
+			level = fmt.Sprint(entry.level)
+		}
+		values := []string{fmt.Sprint(entry.rank), entry.name, fmt.Sprint(entry.score), level, entry.difficulty, entry.mode, date}
+		if entry.difficulty == "" { // saved before sessions were recorded
+			values[4], values[5] = "-", "-"
+		}
+		rowColor := color.Color(color.White)
+		if me != "" && strings.EqualFold(entry.name, me) {
+			rowColor = colornames.Yellow
+		}
+		if entry.tampered {
+			values[1] += " (altered)"
+			rowColor = color.Gray{0x80}
+		}
+		for i := range values {
+			text.Draw(screen, values[i], smallFont, columns[i], 345+25*row, rowColor)
+		}
+	}
+
+	if view.total > 0 {
+		shown := fmt.Sprintf("%d-%d of %d", view.query.offset+1, view.query.offset+len(view.entries), view.total)
+		text.Draw(screen, shown, smallFont, ScreenWidth-200, 290, color.White)
+	}
+	text.Draw(screen, "Up / Down to scroll, Left / Right for a page, F2, Tab or Backspace to go back", smallFont, 50, ScreenHeight-30, color.White)
+}
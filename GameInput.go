@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"strings"
+)
+
+// Bindings maps each action to the keyboard keys that trigger it
+type Bindings map[Action][]ebiten.Key
+
+func defaultBindings() Bindings {
+	return Bindings{
+		ActionUp:    {ebiten.KeyUp},
+		ActionDown:  {ebiten.KeyDown},
+		ActionLeft:  {ebiten.KeyLeft},
+		ActionRight: {ebiten.KeyRight},
+		ActionDash:  {ebiten.KeySpace},
+
+		ActionShootUp:    {ebiten.KeyW},
+		ActionShootDown:  {ebiten.KeyS},
+		ActionShootLeft:  {ebiten.KeyA},
+		ActionShootRight: {ebiten.KeyD},
+		ActionPause:      {ebiten.KeyP, ebiten.KeyEscape},
+		ActionConfirm:    {ebiten.KeyEnter, ebiten.KeyKPEnter},
+		ActionBack:       {ebiten.KeyBackspace},
+	}
+}
+
+// partnerBindings are player two's keys in co-op, on the other side of the keyboard from player one's
+func partnerBindings() Bindings {
+	return Bindings{
+		ActionUp:    {ebiten.KeyI},
+		ActionDown:  {ebiten.KeyK},
+		ActionLeft:  {ebiten.KeyJ},
+		ActionRight: {ebiten.KeyL},
+		ActionDash:  {ebiten.KeyKP0, ebiten.KeyO},
+
+		ActionShootUp:    {ebiten.KeyKP8},
+		ActionShootDown:  {ebiten.KeyKP5, ebiten.KeyKP2},
+		ActionShootLeft:  {ebiten.KeyKP4},
+		ActionShootRight: {ebiten.KeyKP6},
+	}
+}
+
+// menuKey is true for the keys confirm and back have, the menus need them in the middle of a run too
+func (bindings Bindings) menuKey(key ebiten.Key) bool {
+	for _, action := range [...]Action{ActionConfirm, ActionBack} {
+		for _, bound := range bindings[action] {
+			if bound == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rebind gives the key to the action, swapping keys with whichever action had it before.
+// The menus' own keys cannot be taken.
+func (bindings Bindings) rebind(action Action, key ebiten.Key) error {
+	if bindings.menuKey(key) {
+		return fmt.Errorf("%s is kept for the menus, pick another key", key)
+	}
+	oldKeys := bindings[action]
+	for other, keys := range bindings {
+		if other == action || other == ActionConfirm || other == ActionBack {
+			continue
+		}
+		var kept []ebiten.Key
+		hadKey := false
+		for _, bound := range keys {
+			if bound != key {
+				kept = append(kept, bound)
+			} else {
+				hadKey = true
+			}
+		}
+		if !hadKey {
+			continue
+		}
+		if len(kept) == 0 { // a copy, two actions that both had the key must not share one list
+			kept = append([]ebiten.Key(nil), oldKeys...)
+		}
+		bindings[other] = kept
+	}
+	bindings[action] = []ebiten.Key{key}
+	return nil
+}
+
+func (bindings Bindings) describe(action Action) string {
+	var names []string
+	for _, key := range bindings[action] {
+		names = append(names, "'"+key.String()+"'")
+	}
+	return strings.Join(names, " or ")
+}
+
+func (bindings Bindings) encode(action Action) string {
+	var names []string
+	for _, key := range bindings[action] {
+		names = append(names, key.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func decodeKeys(value string) []ebiten.Key {
+	var keys []ebiten.Key
+	for _, name := range strings.Split(value, ",") {
+		for key := ebiten.Key(0); key <= ebiten.KeyMax; key++ {
+			if key.String() == name {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+type keyboardInput struct {
+	bindings Bindings
+}
+
+func (keyboard keyboardInput) Poll() InputFrame {
+	var frame InputFrame
+	for action, keys := range keyboard.bindings {
+		for _, key := range keys {
+			if ebiten.IsKeyPressed(key) {
+				frame.Set(action)
+			}
+		}
+	}
+	return frame
+}
+
+// mouseInput aims at the cursor and throws on the left button
+type mouseInput struct{}
+
+func (mouseInput) Poll() InputFrame {
+	var frame InputFrame
+	frame.aimX, frame.aimY = ebiten.CursorPosition()
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		frame.Set(ActionThrow)
+	}
+	return frame
+}
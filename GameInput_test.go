@@ -0,0 +1,77 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  Action
+		key     ebiten.Key
+		wantErr bool
+		want    map[Action][]ebiten.Key
+	}{
+		{"a free key", ActionDash, ebiten.KeyZ, false,
+			map[Action][]ebiten.Key{ActionDash: {ebiten.KeyZ}}},
+		{"a key another action has is swapped", ActionUp, ebiten.KeyW, false,
+			map[Action][]ebiten.Key{ActionUp: {ebiten.KeyW}, ActionShootUp: {ebiten.KeyUp}}},
+		{"one of two keys is taken", ActionDash, ebiten.KeyEscape, false,
+			map[Action][]ebiten.Key{ActionDash: {ebiten.KeyEscape}, ActionPause: {ebiten.KeyP}}},
+		{"enter is confirm's", ActionRight, ebiten.KeyEnter, true,
+			map[Action][]ebiten.Key{ActionRight: {ebiten.KeyRight}, ActionConfirm: {ebiten.KeyEnter, ebiten.KeyKPEnter}}},
+		{"the keypad enter is confirm's too", ActionShootDown, ebiten.KeyKPEnter, true,
+			map[Action][]ebiten.Key{ActionShootDown: {ebiten.KeyS}}},
+		{"backspace is back's", ActionPause, ebiten.KeyBackspace, true,
+			map[Action][]ebiten.Key{ActionPause: {ebiten.KeyP, ebiten.KeyEscape}, ActionBack: {ebiten.KeyBackspace}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bindings := defaultBindings()
+			err := bindings.rebind(test.action, test.key)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("rebind: %v", err)
+			}
+			for action, keys := range test.want {
+				if got := bindings.encode(action); got != (Bindings{action: keys}).encode(action) {
+					t.Errorf("%s is bound to %s", actionNames[action], got)
+				}
+			}
+		})
+	}
+}
+
+func TestSavedMenuKeysAreDropped(t *testing.T) {
+	game := &Game{bindings: Bindings{}}
+	applySettings(game, map[string]string{
+		settingName(ActionUp):   "Enter,W",
+		settingName(ActionDash): "Backspace",
+	})
+	if got := game.bindings.encode(ActionUp); got != "W" {
+		t.Errorf("move up is bound to %s", got)
+	}
+	if got := game.bindings.encode(ActionDash); got != "Space" {
+		t.Errorf("dash is bound to %s, a saved menu key should fall back to the default", got)
+	}
+}
+
+func TestRebindOnlySwapsWithTheKeysOwner(t *testing.T) {
+	bindings := defaultBindings()
+	bindings[ActionDash] = nil                        // unbound, it stays that way
+	bindings[ActionPause] = []ebiten.Key{ebiten.KeyW} // saved settings gave shoot up's key to pause as well
+	if err := bindings.rebind(ActionUp, ebiten.KeyW); err != nil {
+		t.Fatal(err)
+	}
+	if got := bindings.encode(ActionDash); got != "" {
+		t.Errorf("dash picked up %s", got)
+	}
+	if bindings.encode(ActionShootUp) != "Up" || bindings.encode(ActionPause) != "Up" {
+		t.Fatalf("shoot up is bound to %s and pause to %s, both should have move up's old key",
+			bindings.encode(ActionShootUp), bindings.encode(ActionPause))
+	}
+	bindings[ActionShootUp][0] = ebiten.KeyI
+	if got := bindings.encode(ActionPause); got != "Up" {
+		t.Errorf("changing shoot up's keys changed pause's to %s", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// jsonSession is a Session as it is written in the json store's file
+type jsonSession struct {
+	ID         int       `json:"id"`
+	ProfileID  int       `json:"profile_id"`
+	PlayerNum  int       `json:"player_num"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	Ticks      int       `json:"ticks"`
+	Score      int       `json:"score"`
+	Level      int       `json:"level"`
+	LivesLeft  int       `json:"lives_left"`
+	Outcome    string    `json:"outcome,omitempty"`
+	Difficulty string    `json:"difficulty"`
+	Mode       string    `json:"mode"`
+	Seed       int64     `json:"seed"`
+	Version    string    `json:"game_version"`
+}
+
+// jsonSubmission is a run waiting in the outbox as it is written in the json store's file
+type jsonSubmission struct {
+	Key       string    `json:"idempotency_key"`
+	Body      string    `json:"body"`
+	Attempts  int       `json:"attempts"`
+	NextTry   time.Time `json:"next_try"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+type jsonFile struct {
+	SignedScores bool              `json:"signed_scores"` // false in files saved before scores were signed
+	Profiles     []memoryProfile   `json:"profiles"`
+	Runs         []memoryRun       `json:"runs"`
+	Sessions     []jsonSession     `json:"sessions"`
+	Outbox       []jsonSubmission  `json:"outbox,omitempty"`
+	Answers      map[string]string `json:"answers,omitempty"`
+}
+
+// errFileLocked is lockFile's answer when another process holds the lock
+var errFileLocked = errors.New("it is open in another copy of the game")
+
+// NewJSONStore is a memory store that rewrites its whole file after every change. It needs no cgo,
+// and since the new file is renamed over the old one a crash mid write never leaves half a file behind.
+// Each copy would overwrite the others' changes, so only one copy at a time may have the file open,
+// it holds the lock file next to it until Close.
+func NewJSONStore(file string, key ScoreKey) (*MemoryStore, error) {
+	release, err := lockFile(file + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("open json store %s: %w", file, err)
+	}
+	store, err := loadJSONStore(file, key)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	store.release = release
+	return store, nil
+}
+
+func loadJSONStore(file string, key ScoreKey) (*MemoryStore, error) {
+	store := NewMemoryStore()
+	store.key = key
+	contents, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("open json store %s: %w", file, err)
+	}
+	if err == nil {
+		var saved jsonFile
+		if err := json.Unmarshal(contents, &saved); err != nil {
+			return nil, fmt.Errorf("open json store %s: %w", file, err)
+		}
+		store.profiles, store.runs = saved.Profiles, saved.Runs
+		for _, session := range saved.Sessions {
+			store.sessions = append(store.sessions, Session{
+				id: session.ID, profileID: session.ProfileID, playerNum: session.PlayerNum,
+				startedAt: session.StartedAt, endedAt: session.EndedAt, ticks: session.Ticks,
+				score: session.Score, level: session.Level, livesLeft: session.LivesLeft, outcome: session.Outcome,
+				difficulty: session.Difficulty, mode: session.Mode, seed: session.Seed, version: session.Version,
+			})
+		}
+		for _, submission := range saved.Outbox {
+			store.outbox = append(store.outbox, Submission{key: submission.Key, body: submission.Body,
+				attempts: submission.Attempts, nextTry: submission.NextTry, lastError: submission.LastError})
+		}
+		for key, answer := range saved.Answers {
+			store.answers[key] = answer
+		}
+		// runs saved before scores were signed are trusted once, the same as the SQLite migration does
+		if !saved.SignedScores {
+			for _, run := range store.runs {
+				store.sign(run.PlayerNum, true)
+			}
+		}
+	}
+	store.persist = func(store *MemoryStore) error {
+		return writeJSONStore(file, store)
+	}
+	return store, nil
+}
+
+func writeJSONStore(file string, store *MemoryStore) error {
+	saved := jsonFile{SignedScores: true, Profiles: store.profiles, Runs: store.runs, Answers: store.answers}
+	for _, session := range store.sessions {
+		saved.Sessions = append(saved.Sessions, jsonSession{
+			ID: session.id, ProfileID: session.profileID, PlayerNum: session.playerNum,
+			StartedAt: session.startedAt, EndedAt: session.endedAt, Ticks: session.ticks,
+			Score: session.score, Level: session.level, LivesLeft: session.livesLeft, Outcome: session.outcome,
+			Difficulty: session.difficulty, Mode: session.mode, Seed: session.seed, Version: session.version,
+		})
+	}
+	for _, submission := range store.outbox {
+		saved.Outbox = append(saved.Outbox, jsonSubmission{Key: submission.key, Body: submission.body,
+			Attempts: submission.attempts, NextTry: submission.nextTry, LastError: submission.lastError})
+	}
+	contents, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// the temporary file sits next to the real one so the rename never crosses file systems
+	temp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONStoreIsOpenOnce(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scores.json")
+	ctx := context.Background()
+	key := randomScoreKey()
+	first, err := NewJSONStore(file, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.EnsureProfile(ctx, "Huy"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second copy would write over the first one's changes, so it is turned away
+	if _, err := NewJSONStore(file, key); err == nil || !strings.Contains(err.Error(), "open in another copy of the game") {
+		t.Fatalf("second open: %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewJSONStore(file, key)
+	if err != nil {
+		t.Fatalf("open after the first copy closed: %v", err)
+	}
+	defer second.Close()
+	if _, found, err := second.FindProfile(ctx, "huy"); err != nil || !found {
+		t.Fatalf("the first copy's profile: %v %v", found, err)
+	}
+}
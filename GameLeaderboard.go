@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window limits the leaderboard to runs started in a recent stretch of time
+type Window int
+
+const (
+	WindowAllTime Window = iota
+	WindowDaily
+	WindowWeekly
+	numWindows
+)
+
+var windowNames = [numWindows]string{"All Time", "Today", "This Week"}
+
+// since returns when the window opens, the zero time for all time
+func (window Window) since(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch window {
+	case WindowDaily:
+		return midnight
+	case WindowWeekly:
+		return midnight.AddDate(0, 0, -6)
+	}
+	return time.Time{}
+}
+
+type LeaderboardQuery struct {
+	window     Window
+	difficulty string // "" for every difficulty
+	mode       string // "" for every mode
+	now        time.Time
+	limit      int
+	offset     int
+}
+
+type LeaderboardEntry struct {
+	rank       int
+	playerNum  int
+	name       string
+	score      int
+	level      int
+	difficulty string
+	mode       string
+	playedAt   time.Time // zero for runs saved before sessions were recorded
+	tampered   bool      // the run no longer matches its signature, it was changed outside the game
+}
+
+// leaderboardSQL ranks every run that matches the query, runs from before sessions existed only count for all time
+func leaderboardSQL(query LeaderboardQuery) (string, []interface{}) {
+	// a run that did not match its replay stays off the board until it is accepted
+	where := []string{"players.player_num NOT IN (SELECT player_num FROM review_queue WHERE status != 'accepted')"}
+	var args []interface{}
+	if since := query.window.since(query.now); !since.IsZero() {
+		where = append(where, "sessions.started_at >= ?")
+		args = append(args, since.UTC().Format(time.RFC3339))
+	}
+	if query.difficulty != "" {
+		where = append(where, "sessions.difficulty = ?")
+		args = append(args, query.difficulty)
+	}
+	if query.mode != "" {
+		where = append(where, "sessions.mode = ?")
+		args = append(args, query.mode)
+	}
+	statement := "SELECT players.player_num, players.player_name, players.player_score, " +
+		"IFNULL(sessions.highest_level, 0), IFNULL(sessions.difficulty, ''), IFNULL(sessions.mode, ''), IFNULL(sessions.started_at, ''), " +
+		"IFNULL(sessions.seed, 0), IFNULL(sessions.duration_ms, 0), IFNULL(sessions.game_version, ''), IFNULL(players.signature, ''), " +
+		"RANK() OVER (ORDER BY players.player_score DESC) AS place " +
+		"FROM players LEFT JOIN sessions ON sessions.player_num = players.player_num"
+	statement += " WHERE " + strings.Join(where, " AND ")
+	return statement, args
+}
+
+func (store *SQLiteStore) scanLeaderboard(ctx context.Context, statement string, args ...interface{}) ([]LeaderboardEntry, error) {
+	rows, err := store.conn().QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("leaderboard: %w", err)
+	}
+	defer rows.Close()
+	var entries []LeaderboardEntry
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var playedAt, signature string
+		var record ScoreRecord
+		err := rows.Scan(&entry.playerNum, &entry.name, &entry.score, &entry.level, &entry.difficulty, &entry.mode, &playedAt,
+			&record.seed, &record.duration, &record.version, &signature, &entry.rank)
+		if err != nil {
+			return nil, fmt.Errorf("leaderboard: %w", err)
+		}
+		entry.playedAt, _ = time.Parse(time.RFC3339, playedAt)
+		record.player, record.score = entry.name, entry.score
+		entry.tampered = !store.key.verify(record, signature)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Leaderboard returns one page of the ranked scores and how many scores the whole board has
+func (store *SQLiteStore) Leaderboard(ctx context.Context, query LeaderboardQuery) ([]LeaderboardEntry, int, error) {
+	board, args := leaderboardSQL(query)
+	var total int
+	if err := store.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+board+")", args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("leaderboard size: %w", err)
+	}
+	statement := "SELECT * FROM (" + board + ") ORDER BY place, player_num LIMIT ? OFFSET ?"
+	entries, err := store.scanLeaderboard(ctx, statement, append(args, query.limit, query.offset)...)
+	return entries, total, err
+}
+
+// LeaderboardRank finds where one run places on the board, false if the run is not on it
+func (store *SQLiteStore) LeaderboardRank(ctx context.Context, query LeaderboardQuery, playerNum int) (LeaderboardEntry, bool, error) {
+	board, args := leaderboardSQL(query)
+	statement := "SELECT * FROM (" + board + ") WHERE player_num = ?"
+	entries, err := store.scanLeaderboard(ctx, statement, append(args, playerNum)...)
+	if err != nil || len(entries) == 0 {
+		return LeaderboardEntry{}, false, err
+	}
+	return entries[0], true, nil
+}
+
+// LeaderboardView is the part of the leaderboard the game is showing, refreshed whenever a score is written
+type LeaderboardView struct {
+	query   LeaderboardQuery
+	entries []LeaderboardEntry
+	total   int
+	mine    LeaderboardEntry
+	hasMine bool
+}
+
+func newLeaderboardView(limit int) LeaderboardView {
+	return LeaderboardView{query: LeaderboardQuery{window: WindowAllTime, limit: limit}}
+}
+
+func (view *LeaderboardView) Refresh(ctx context.Context, store ScoreStore, playerNum int) error {
+	view.query.now = time.Now()
+	entries, total, err := store.Leaderboard(ctx, view.query)
+	if err != nil {
+		return err
+	}
+	view.entries, view.total = entries, total
+	view.hasMine = false
+	if playerNum != 0 {
+		view.mine, view.hasMine, err = store.LeaderboardRank(ctx, view.query, playerNum)
+	}
+	return err
+}
+
+// scroll moves the page by some rows without running past either end of the board
+func (view *LeaderboardView) scroll(rows int) {
+	view.query.offset += rows
+	if last := view.total - view.query.limit; view.query.offset > last {
+		view.query.offset = last
+	}
+	if view.query.offset < 0 {
+		view.query.offset = 0
+	}
+}
+
+// nextFilter steps through the choices of a filter, "" standing for all of them
+func nextFilter(choices []string, current string) string {
+	for i := range choices {
+		if choices[i] == current && i+1 < len(choices) {
+			return choices[i+1]
+		}
+	}
+	return ""
+}
+
+func filterName(value string) string {
+	if value == "" {
+		return "All"
+	}
+	return value
+}
+
+// arcadeQualifies tells whether a score would make the all time top scores, a full board has to be beaten outright
+func arcadeQualifies(ctx context.Context, store ScoreStore, score int, top int) (bool, error) {
+	if score <= 0 {
+		return false, nil
+	}
+	entries, _, err := store.Leaderboard(ctx, LeaderboardQuery{window: WindowAllTime, now: time.Now(), limit: top})
+	if err != nil {
+		return false, err
+	}
+	return len(entries) < top || score > entries[top-1].score, nil
+}
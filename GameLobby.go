@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/colornames"
+	"image/color"
+	"log"
+	"net"
+	"strings"
+)
+
+const (
+	lobbyHost = iota
+	lobbyJoin
+	lobbyBack
+	numLobbyRows
+)
+
+// Lobby is the online co-op screen, hosting waits for the other player on netPort and joining connects to a host
+type Lobby struct {
+	cursor  int
+	address string
+	waiting string // what the lobby is waiting on, "" when it is not
+	problem string
+	cancel  func()
+	result  chan lobbyResult
+}
+
+type lobbyResult struct {
+	game *NetGame
+	err  error
+}
+
+func openLobby(game *Game) {
+	loadProfile(game, "Loading your settings", func() {
+		game.lobbyOpen = true
+		game.lobby.cursor = 0
+		game.lobby.problem = ""
+	})
+}
+
+func lobbyScreen(game *Game) {
+	lobby := &game.lobby
+	if lobby.waiting != "" {
+		select {
+		case result := <-lobby.result:
+			lobby.waiting, lobby.cancel = "", nil
+			if result.err != nil {
+				lobby.problem = result.err.Error()
+				return
+			}
+			game.lobbyOpen = false
+			startNetGame(game, result.game)
+		default:
+			if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || game.justPressed(ActionBack) {
+				lobby.cancel()
+				lobby.waiting, lobby.cancel = "", nil
+			}
+		}
+		return
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF4) || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		game.lobbyOpen = false
+		return
+	}
+	if game.justPressed(ActionUp) && lobby.cursor > 0 {
+		lobby.cursor--
+	} else if game.justPressed(ActionDown) && lobby.cursor < numLobbyRows-1 {
+		lobby.cursor++
+	}
+	if lobby.cursor == lobbyJoin {
+		lobby.address += strings.TrimSpace(string(ebiten.InputChars()))
+		if playerTyping(ebiten.KeyBackspace) && len(lobby.address) > 0 {
+			lobby.address = lobby.address[:len(lobby.address)-1]
+		}
+	}
+	if !game.justPressed(ActionConfirm) {
+		return
+	}
+
+	lobby.problem = ""
+	lobby.result = make(chan lobbyResult, 1)
+	switch lobby.cursor {
+	case lobbyHost:
+		listener, err := net.Listen("tcp", ":"+netPort)
+		if err != nil {
+			lobby.problem = err.Error()
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		start := newNetStart(&game.World)
+		lobby.cancel = func() {
+			cancel()
+			listener.Close()
+		}
+		go func() {
+			netGame, err := hostNetGame(listener, start, GameVersion)
+			listener.Close()
+			lobby.deliver(ctx, netGame, err)
+		}()
+		lobby.waiting = "Waiting for a player to join on port " + netPort
+	case lobbyJoin:
+		if lobby.address == "" {
+			lobby.problem = "type the host's address first"
+			return
+		}
+		address := lobby.address
+		ctx, cancel := context.WithCancel(context.Background())
+		lobby.cancel = cancel
+		go func() {
+			netGame, err := joinNetGame(ctx, address, GameVersion)
+			lobby.deliver(ctx, netGame, err)
+		}()
+		lobby.waiting = "Connecting to " + address
+	case lobbyBack:
+		game.lobbyOpen = false
+	}
+}
+
+// deliver hands the connection to the lobby, one that comes in after the player gave up waiting is hung up
+func (lobby *Lobby) deliver(ctx context.Context, netGame *NetGame, err error) {
+	if ctx.Err() != nil {
+		if netGame != nil {
+			netGame.Close()
+		}
+		return
+	}
+	lobby.result <- lobbyResult{game: netGame, err: err}
+}
+
+// startNetGame starts the online run once both players are in, each machine saves it under the name typed on it
+func startNetGame(game *Game, netGame *NetGame) {
+	game.net = netGame
+	game.localDog = netGame.local
+	game.netProblem = ""
+	game.gamepads.split = false
+	playerText = game.infoBar.playerName
+	startGame(game)
+}
+
+// netTick plays this machine's input into the world once the other machine's input for the tick is in too,
+// false means the tick has to wait
+func netTick(game *Game) bool {
+	frames, ready, err := game.net.Next(game.input.Poll(), game.stateHash())
+	if err != nil {
+		dropNetGame(game, err)
+		return false
+	}
+	if ready {
+		game.nextFrame(frames[:]...)
+	}
+	return ready
+}
+
+// dropNetGame ends an online run that cannot go on, the run so far is saved as quit
+func dropNetGame(game *Game, err error) {
+	game.netProblem = err.Error()
+	log.Println("online co-op:", game.netProblem)
+	leaveNetGame(game)
+	saveScore(game)
+	endSession(game, OutcomeQuit)
+	updateScore++
+	endScreen(&game.World)
+	game.currentLevel = 4
+}
+
+func leaveNetGame(game *Game) {
+	if game.net != nil {
+		game.net.Close()
+		game.net = nil
+	}
+}
+
+// localAddresses are the addresses the other player can join this machine on
+func localAddresses() []string {
+	var addresses []string
+	interfaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range interfaceAddrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			addresses = append(addresses, ipNet.IP.String())
+		}
+	}
+	return addresses
+}
+
+func (game Game) drawLobby(screen *ebiten.Image) {
+	smallFont := makeFont(14, 72)
+	lobby := game.lobby
+	text.Draw(screen, "Online co-op", makeFont(30, 72), 50, 260, colornames.Tomato)
+	text.Draw(screen, "One of you hosts and the other joins, the host's difficulty and movement are used for the run",
+		smallFont, 50, 290, color.White)
+
+	rows := [numLobbyRows]string{
+		"Host a game on port " + netPort,
+		"Join a game at: " + lobby.address,
+		"Back",
+	}
+	if lobby.cursor == lobbyJoin && lobby.waiting == "" {
+		rows[lobbyJoin] += "_"
+	}
+	for i, row := range rows {
+		rowColor := color.Color(color.White)
+		if i == lobby.cursor {
+			rowColor = colornames.Yellow
+		}
+		text.Draw(screen, row, makeFont(20, 72), 80, 340+40*i, rowColor)
+	}
+	if addresses := localAddresses(); len(addresses) > 0 {
+		text.Draw(screen, "this machine is at "+strings.Join(addresses, ", "), smallFont, 80, 470, color.White)
+	}
+	if lobby.waiting != "" {
+		text.Draw(screen, lobby.waiting+"...", makeFont(20, 72), 80, 520, colornames.Yellow)
+		text.Draw(screen, "Escape to stop waiting", smallFont, 80, 545, color.White)
+	} else if lobby.problem != "" {
+		text.Draw(screen, lobby.problem, smallFont, 80, 520, colornames.Tomato)
+	}
+	text.Draw(screen, "Up / Down to choose, type the host's address (host or host:port) and press Enter, F4 or Escape to go back",
+		smallFont, 50, ScreenHeight-30, color.White)
+}
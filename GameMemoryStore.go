@@ -0,0 +1,553 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryProfile struct {
+	ID       int               `json:"id"`
+	Name     string            `json:"name"`
+	Settings map[string]string `json:"settings,omitempty"`
+	Unlocks  []string          `json:"unlocks,omitempty"`
+}
+
+type memoryRun struct {
+	PlayerNum int           `json:"player_num"`
+	ProfileID int           `json:"profile_id"`
+	Name      string        `json:"name"`
+	Score     int           `json:"score"`
+	Signature string        `json:"signature,omitempty"`
+	Review    *memoryReview `json:"review,omitempty"`
+}
+
+// memoryReview is a run waiting in the review queue. Only these runs keep their replay,
+// the json store rewrites its whole file on every change and a replay for every run would make that slow.
+type memoryReview struct {
+	Reason string `json:"reason"`
+	Status string `json:"status"`
+	Replay string `json:"replay"`
+}
+
+// MemoryStore keeps everything in memory and forgets it when the game closes, with persist set it is the json store
+type MemoryStore struct {
+	lock     sync.Mutex // the game loop and the score writer both use the store
+	profiles []memoryProfile
+	runs     []memoryRun
+	sessions []Session
+	outbox   []Submission
+	answers  map[string]string // what a leaderboard server answered each idempotency key
+	key      ScoreKey
+	persist  func(store *MemoryStore) error // called with the lock held after every change
+	release  func() error                   // lets go of the json store's file, nil for the memory store
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{key: randomScoreKey(), answers: make(map[string]string)}
+}
+
+// changed saves the store after a write, the memory store has nothing to save to
+func (store *MemoryStore) changed() error {
+	if store.persist == nil {
+		return nil
+	}
+	return store.persist(store)
+}
+
+// record is what the run's signature covers, the lock must be held
+func (store *MemoryStore) record(run memoryRun) ScoreRecord {
+	record := ScoreRecord{player: run.Name, score: run.Score}
+	for _, session := range store.sessions {
+		if session.playerNum == run.PlayerNum {
+			record.seed, record.duration, record.version = session.seed, session.duration().Milliseconds(), session.version
+		}
+	}
+	return record
+}
+
+// matches tells whether a run still matches its signature, the lock must be held
+func (store *MemoryStore) matches(playerNum int) bool {
+	for _, run := range store.runs {
+		if run.PlayerNum == playerNum && !store.key.verify(store.record(run), run.Signature) {
+			return false
+		}
+	}
+	return true
+}
+
+// sign signs a run again after one of its signed fields changed, unless it had stopped matching before the change.
+// The lock must be held.
+func (store *MemoryStore) sign(playerNum int, matched bool) {
+	for i := range store.runs {
+		if matched && store.runs[i].PlayerNum == playerNum {
+			store.runs[i].Signature = store.key.sign(store.record(store.runs[i]))
+		}
+	}
+}
+
+func (store *MemoryStore) Migrate(ctx context.Context) error {
+	return nil
+}
+
+func (store *MemoryStore) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.release == nil {
+		return nil
+	}
+	release := store.release
+	store.release = nil
+	return release()
+}
+
+func (store *MemoryStore) profileNamed(playername string) *memoryProfile {
+	for i := range store.profiles {
+		if strings.EqualFold(store.profiles[i].Name, strings.TrimSpace(playername)) {
+			return &store.profiles[i]
+		}
+	}
+	return nil
+}
+
+func (store *MemoryStore) profileWithID(profileID int) (*memoryProfile, error) {
+	for i := range store.profiles {
+		if store.profiles[i].ID == profileID {
+			return &store.profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no profile %d", profileID)
+}
+
+func (store *MemoryStore) FindProfile(ctx context.Context, playername string) (Profile, bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	found := store.profileNamed(playername)
+	if found == nil {
+		return Profile{}, false, nil
+	}
+	profile := Profile{id: found.ID, name: found.Name, unlocks: append([]string(nil), found.Unlocks...)}
+	for _, run := range store.runs {
+		if run.ProfileID == found.ID {
+			profile.runs++
+			if run.Score > profile.best {
+				profile.best = run.Score
+			}
+		}
+	}
+	return profile, true, nil
+}
+
+func (store *MemoryStore) EnsureProfile(ctx context.Context, playername string) (Profile, error) {
+	store.lock.Lock()
+	if store.profileNamed(playername) == nil {
+		store.profiles = append(store.profiles, memoryProfile{ID: len(store.profiles) + 1, Name: strings.TrimSpace(playername)})
+		if err := store.changed(); err != nil {
+			store.lock.Unlock()
+			return Profile{}, fmt.Errorf("save profile %q: %w", playername, err)
+		}
+	}
+	store.lock.Unlock()
+	profile, _, err := store.FindProfile(ctx, playername)
+	return profile, err
+}
+
+func (store *MemoryStore) AddPlayer(ctx context.Context, profile Profile) (int, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	playerNum := len(store.runs) + 1
+	store.runs = append(store.runs, memoryRun{PlayerNum: playerNum, ProfileID: profile.id, Name: profile.name})
+	store.sign(playerNum, true)
+	if err := store.changed(); err != nil {
+		return 0, fmt.Errorf("add player %q: %w", profile.name, err)
+	}
+	return playerNum, nil
+}
+
+func (store *MemoryStore) UpdateScore(ctx context.Context, id int, score int) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for i := range store.runs {
+		if store.runs[i].PlayerNum == id {
+			matched := store.matches(id)
+			store.runs[i].Score = score
+			store.sign(id, matched)
+			if err := store.changed(); err != nil {
+				return fmt.Errorf("update score: %w", err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("update score: no player number %d", id)
+}
+
+// board ranks the runs matching the query the same way leaderboardSQL does
+func (store *MemoryStore) board(query LeaderboardQuery) []LeaderboardEntry {
+	sessions := make(map[int]Session)
+	for _, session := range store.sessions {
+		sessions[session.playerNum] = session
+	}
+	since := query.window.since(query.now)
+	var entries []LeaderboardEntry
+	for _, run := range store.runs {
+		session, hasSession := sessions[run.PlayerNum]
+		if (!since.IsZero() || query.difficulty != "" || query.mode != "") && !hasSession {
+			continue
+		}
+		if session.startedAt.Before(since) ||
+			query.difficulty != "" && session.difficulty != query.difficulty ||
+			query.mode != "" && session.mode != query.mode {
+			continue
+		}
+		if run.Review != nil && run.Review.Status != ReviewAccepted {
+			continue
+		}
+		entries = append(entries, LeaderboardEntry{playerNum: run.PlayerNum, name: run.Name, score: run.Score,
+			level: session.level, difficulty: session.difficulty, mode: session.mode, playedAt: session.startedAt,
+			tampered: !store.key.verify(store.record(run), run.Signature)})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].score != entries[j].score {
+			return entries[i].score > entries[j].score
+		}
+		return entries[i].playerNum < entries[j].playerNum
+	})
+	for i := range entries {
+		entries[i].rank = i + 1
+		if i > 0 && entries[i].score == entries[i-1].score {
+			entries[i].rank = entries[i-1].rank
+		}
+	}
+	return entries
+}
+
+func (store *MemoryStore) Leaderboard(ctx context.Context, query LeaderboardQuery) ([]LeaderboardEntry, int, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	entries := store.board(query)
+	total := len(entries)
+	if query.offset > len(entries) {
+		query.offset = len(entries)
+	}
+	entries = entries[query.offset:]
+	if query.limit < len(entries) {
+		entries = entries[:query.limit]
+	}
+	return entries, total, nil
+}
+
+func (store *MemoryStore) LeaderboardRank(ctx context.Context, query LeaderboardQuery, playerNum int) (LeaderboardEntry, bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, entry := range store.board(query) {
+		if entry.playerNum == playerNum {
+			return entry, true, nil
+		}
+	}
+	return LeaderboardEntry{}, false, nil
+}
+
+func (store *MemoryStore) LoadSettings(ctx context.Context, profileID int) (map[string]string, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	settings := make(map[string]string)
+	if profile, err := store.profileWithID(profileID); err == nil {
+		for setting, value := range profile.Settings {
+			settings[setting] = value
+		}
+	}
+	return settings, nil
+}
+
+func (store *MemoryStore) SaveSettings(ctx context.Context, profileID int, settings map[string]string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	profile, err := store.profileWithID(profileID)
+	if err != nil {
+		return fmt.Errorf("save settings for profile %d: %w", profileID, err)
+	}
+	if profile.Settings == nil {
+		profile.Settings = make(map[string]string)
+	}
+	for setting, value := range settings {
+		profile.Settings[setting] = value
+	}
+	if err := store.changed(); err != nil {
+		return fmt.Errorf("save settings for profile %d: %w", profileID, err)
+	}
+	return nil
+}
+
+func (store *MemoryStore) Unlock(ctx context.Context, profileID int, unlock string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	profile, err := store.profileWithID(profileID)
+	if err != nil {
+		return fmt.Errorf("unlock %q for profile %d: %w", unlock, profileID, err)
+	}
+	for _, got := range profile.Unlocks {
+		if got == unlock {
+			return nil
+		}
+	}
+	profile.Unlocks = append(profile.Unlocks, unlock)
+	if err := store.changed(); err != nil {
+		return fmt.Errorf("unlock %q for profile %d: %w", unlock, profileID, err)
+	}
+	return nil
+}
+
+func (store *MemoryStore) StartSession(ctx context.Context, session *Session) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	session.id = len(store.sessions) + 1
+	matched := store.matches(session.playerNum)
+	store.sessions = append(store.sessions, *session)
+	store.sign(session.playerNum, matched)
+	if err := store.changed(); err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	return nil
+}
+
+// saveSession replaces the stored session, a checkpoint leaves a session that has already finished alone
+func (store *MemoryStore) saveSession(session Session, checkpoint bool) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for i := range store.sessions {
+		if store.sessions[i].id != session.id {
+			continue
+		}
+		if checkpoint {
+			if store.sessions[i].outcome != "" {
+				return nil
+			}
+			session.endedAt, session.outcome = store.sessions[i].endedAt, store.sessions[i].outcome
+		}
+		matched := store.matches(session.playerNum)
+		store.sessions[i] = session
+		store.sign(session.playerNum, matched)
+		return store.changed()
+	}
+	return fmt.Errorf("no session %d", session.id)
+}
+
+func (store *MemoryStore) FinishSession(ctx context.Context, session Session) error {
+	if err := store.saveSession(session, false); err != nil {
+		return fmt.Errorf("finish session %d: %w", session.id, err)
+	}
+	return nil
+}
+
+func (store *MemoryStore) CheckpointSession(ctx context.Context, session Session) error {
+	if err := store.saveSession(session, true); err != nil {
+		return fmt.Errorf("checkpoint session %d: %w", session.id, err)
+	}
+	return nil
+}
+
+func (store *MemoryStore) RecentSessions(ctx context.Context, profileID int, limit int) ([]Session, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var sessions []Session
+	for _, session := range store.sessions {
+		if session.profileID == profileID && session.outcome != "" {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if !sessions[i].startedAt.Equal(sessions[j].startedAt) {
+			return sessions[i].startedAt.After(sessions[j].startedAt)
+		}
+		return sessions[i].id > sessions[j].id
+	})
+	if limit < len(sessions) {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+func (store *MemoryStore) PersonalBests(ctx context.Context, profileID int) (PersonalBests, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var bests PersonalBests
+	for _, session := range store.sessions {
+		if session.profileID != profileID || session.outcome == "" {
+			continue
+		}
+		bests.runs++
+		if session.score > bests.bestScore {
+			bests.bestScore = session.score
+		}
+		if session.level > bests.highestLevel {
+			bests.highestLevel = session.level
+		}
+		if session.duration() > bests.longestRun {
+			bests.longestRun = session.duration()
+		}
+		if session.outcome == OutcomeWon {
+			bests.wins++
+			if bests.fastestWin == 0 || session.duration() < bests.fastestWin {
+				bests.fastestWin = session.duration()
+			}
+		}
+	}
+	return bests, nil
+}
+
+func (store *MemoryStore) SubmitReplay(ctx context.Context, playerNum int, replay Replay) (string, error) {
+	// the replay is played back without the lock, it takes a while
+	store.lock.Lock()
+	score, session, found := 0, Session{}, false
+	for _, run := range store.runs {
+		if run.PlayerNum == playerNum {
+			score, found = run.Score, true
+		}
+	}
+	for _, saved := range store.sessions {
+		if saved.playerNum == playerNum {
+			session = saved
+		}
+	}
+	store.lock.Unlock()
+	if !found {
+		return "", fmt.Errorf("submit replay: no player number %d", playerNum)
+	}
+	mismatch, err := checkReplay(ctx, replay, score, session)
+	if err != nil {
+		return "", fmt.Errorf("submit replay for run %d: %w", playerNum, err)
+	}
+	if err := store.SaveReplay(ctx, playerNum, replay, mismatch); err != nil {
+		return "", err
+	}
+	return mismatch, nil
+}
+
+func (store *MemoryStore) SaveReplay(ctx context.Context, playerNum int, replay Replay, reason string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for i := range store.runs {
+		if store.runs[i].PlayerNum != playerNum {
+			continue
+		}
+		store.runs[i].Review = nil
+		if reason != "" {
+			store.runs[i].Review = &memoryReview{Reason: reason, Status: ReviewPending, Replay: replay.encode()}
+		}
+		if err := store.changed(); err != nil {
+			return fmt.Errorf("save replay for run %d: %w", playerNum, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("save replay: no player number %d", playerNum)
+}
+
+func (store *MemoryStore) ReviewQueue(ctx context.Context) ([]Review, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var reviews []Review
+	for _, run := range store.runs {
+		if run.Review != nil {
+			reviews = append(reviews, Review{playerNum: run.PlayerNum, name: run.Name, score: run.Score,
+				reason: run.Review.Reason, status: run.Review.Status})
+		}
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].status == ReviewPending && reviews[j].status != ReviewPending
+	})
+	return reviews, nil
+}
+
+func (store *MemoryStore) ReviewRun(ctx context.Context, playerNum int, status string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for i := range store.runs {
+		if store.runs[i].PlayerNum == playerNum && store.runs[i].Review != nil {
+			store.runs[i].Review.Status = status
+			if err := store.changed(); err != nil {
+				return fmt.Errorf("review run %d: %w", playerNum, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("run %d is not waiting for review", playerNum)
+}
+
+func (store *MemoryStore) QueueSubmission(ctx context.Context, submission Submission) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, queued := range store.outbox {
+		if queued.key == submission.key {
+			return nil
+		}
+	}
+	store.outbox = append(store.outbox, submission)
+	if err := store.changed(); err != nil {
+		return fmt.Errorf("queue submission: %w", err)
+	}
+	return nil
+}
+
+func (store *MemoryStore) DueSubmissions(ctx context.Context, now time.Time) ([]Submission, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var due []Submission
+	for _, submission := range store.outbox {
+		if !submission.nextTry.After(now) {
+			due = append(due, submission)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].nextTry.Before(due[j].nextTry)
+	})
+	return due, nil
+}
+
+func (store *MemoryStore) RetrySubmission(ctx context.Context, submission Submission) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for i := range store.outbox {
+		if store.outbox[i].key == submission.key {
+			store.outbox[i] = submission
+		}
+	}
+	if err := store.changed(); err != nil {
+		return fmt.Errorf("retry submission: %w", err)
+	}
+	return nil
+}
+
+func (store *MemoryStore) RemoveSubmission(ctx context.Context, key string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for i := range store.outbox {
+		if store.outbox[i].key == key {
+			store.outbox = append(store.outbox[:i], store.outbox[i+1:]...)
+			break
+		}
+	}
+	if err := store.changed(); err != nil {
+		return fmt.Errorf("remove submission: %w", err)
+	}
+	return nil
+}
+
+func (store *MemoryStore) SubmissionAnswer(ctx context.Context, key string) (string, bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	answer, found := store.answers[key]
+	return answer, found, nil
+}
+
+func (store *MemoryStore) SaveSubmissionAnswer(ctx context.Context, key string, answer string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.answers[key] = answer
+	if err := store.changed(); err != nil {
+		return fmt.Errorf("save submission answer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration moves the schema up by one version, migrations only ever get added to the end of the list
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+var migrations = []migration{
+	{1, "players table", []string{
+		"CREATE TABLE IF NOT EXISTS players(" +
+			"player_num INTEGER PRIMARY KEY," +
+			"player_name TEXT NOT NULL," +
+			"player_score INTEGER DEFAULT 0);",
+	}},
+	{2, "settings table", []string{
+		"CREATE TABLE IF NOT EXISTS settings(" +
+			"player_name TEXT NOT NULL," +
+			"setting TEXT NOT NULL," +
+			"value TEXT NOT NULL," +
+			"PRIMARY KEY (player_name, setting));",
+	}},
+	{3, "profiles", []string{
+		"CREATE TABLE profiles(" +
+			"profile_id INTEGER PRIMARY KEY," +
+			"name TEXT NOT NULL UNIQUE COLLATE NOCASE," +
+			"created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))," +
+			"last_seen TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')));",
+		// every name typed so far becomes one profile, "Huy" and "huy" included
+		"INSERT OR IGNORE INTO profiles (name) SELECT player_name FROM players ORDER BY player_num;",
+		"INSERT OR IGNORE INTO profiles (name) SELECT player_name FROM settings;",
+		"ALTER TABLE players ADD COLUMN profile_id INTEGER REFERENCES profiles(profile_id);",
+		"UPDATE players SET profile_id = (SELECT profile_id FROM profiles WHERE profiles.name = players.player_name);",
+		"CREATE INDEX players_profile ON players(profile_id);",
+		"CREATE TABLE profile_settings(" +
+			"profile_id INTEGER NOT NULL REFERENCES profiles(profile_id)," +
+			"setting TEXT NOT NULL," +
+			"value TEXT NOT NULL," +
+			"PRIMARY KEY (profile_id, setting));",
+		"INSERT OR REPLACE INTO profile_settings (profile_id, setting, value) " +
+			"SELECT profiles.profile_id, settings.setting, settings.value FROM settings JOIN profiles ON profiles.name = settings.player_name;",
+		"DROP TABLE settings;",
+		"CREATE TABLE unlocks(" +
+			"profile_id INTEGER NOT NULL REFERENCES profiles(profile_id)," +
+			"unlock TEXT NOT NULL," +
+			"unlocked_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))," +
+			"PRIMARY KEY (profile_id, unlock));",
+	}},
+	{4, "sessions", []string{
+		"CREATE TABLE sessions(" +
+			"session_id INTEGER PRIMARY KEY," +
+			"profile_id INTEGER NOT NULL REFERENCES profiles(profile_id)," +
+			"player_num INTEGER REFERENCES players(player_num)," +
+			"started_at TEXT NOT NULL," +
+			"ended_at TEXT," +
+			"duration_ms INTEGER," +
+			"final_score INTEGER," +
+			"highest_level INTEGER," +
+			"lives_left INTEGER," +
+			"outcome TEXT CHECK (outcome IN ('won', 'lost', 'quit'))," +
+			"difficulty TEXT NOT NULL DEFAULT 'Normal'," +
+			"seed INTEGER NOT NULL DEFAULT 0," +
+			"game_version TEXT NOT NULL);",
+		"CREATE INDEX sessions_profile ON sessions(profile_id, started_at);",
+	}},
+	{5, "session mode", []string{
+		"ALTER TABLE sessions ADD COLUMN mode TEXT NOT NULL DEFAULT 'solo';",
+		"CREATE INDEX sessions_player ON sessions(player_num);",
+	}},
+	// the runs already saved are signed by the store in the same transaction, see signUnsigned
+	{signedSchema, "score signatures", []string{
+		"ALTER TABLE players ADD COLUMN signature TEXT;",
+	}},
+	{7, "replays and review queue", []string{
+		"CREATE TABLE replays(" +
+			"player_num INTEGER PRIMARY KEY REFERENCES players(player_num)," +
+			"replay TEXT NOT NULL," +
+			"submitted_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')));",
+		"CREATE TABLE review_queue(" +
+			"player_num INTEGER PRIMARY KEY REFERENCES players(player_num)," +
+			"reason TEXT NOT NULL," +
+			"status TEXT NOT NULL CHECK (status IN ('pending', 'accepted', 'rejected'))," +
+			"queued_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')));",
+	}},
+	// the outbox is the game's side of a leaderboard server, received_submissions is the server's side
+	{8, "leaderboard server", []string{
+		"CREATE TABLE outbox(" +
+			"idempotency_key TEXT PRIMARY KEY," +
+			"body TEXT NOT NULL," +
+			"attempts INTEGER NOT NULL," +
+			"next_try TEXT NOT NULL," +
+			"last_error TEXT NOT NULL," +
+			"queued_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')));",
+		"CREATE TABLE received_submissions(" +
+			"idempotency_key TEXT PRIMARY KEY," +
+			"answer TEXT NOT NULL," +
+			"received_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')));",
+	}},
+}
+
+// migrationStep is work a migration needs done in Go, it runs right after the migration's statements
+type migrationStep func(ctx context.Context, tx sqlConn) error
+
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	create_version_table := "CREATE TABLE IF NOT EXISTS schema_version(" +
+		"version INTEGER PRIMARY KEY," +
+		"name TEXT NOT NULL," +
+		"applied_at TEXT NOT NULL);"
+	if _, err := db.ExecContext(ctx, create_version_table); err != nil {
+		return 0, fmt.Errorf("create schema_version table: %w", err)
+	}
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT IFNULL(MAX(version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrate brings the database up to the newest schema and returns how many migrations it applied.
+// Every pending migration runs in one transaction so a failure leaves the database as it was.
+func Migrate(ctx context.Context, db *sql.DB, steps map[int]migrationStep) (int, error) {
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return 0, fmt.Errorf("database schema version %d is newer than this game understands (%d)", current, latest)
+	}
+	if current == latest {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: %w", err)
+	}
+	defer tx.Rollback()
+	// another copy of the game may have migrated the database while this one waited for the write lock
+	if err := tx.QueryRowContext(ctx, "SELECT IFNULL(MAX(version), 0) FROM schema_version").Scan(&current); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+
+	applied := 0
+	for _, step := range migrations {
+		if step.version <= current {
+			continue
+		}
+		for _, statement := range step.statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return 0, fmt.Errorf("migration %d (%s): %w", step.version, step.name, err)
+			}
+		}
+		if goStep, ok := steps[step.version]; ok {
+			if err := goStep(ctx, tx); err != nil {
+				return 0, fmt.Errorf("migration %d (%s): %w", step.version, step.name, err)
+			}
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+			step.version, step.name, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return 0, fmt.Errorf("migration %d (%s): %w", step.version, step.name, err)
+		}
+		applied++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("migrate: %w", err)
+	}
+	return applied, nil
+}
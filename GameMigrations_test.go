@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openTestDatabase opens a new SQLite file in the test's temp dir, tests that need SQLite skip without cgo
+func openTestDatabase(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	if !sqliteAvailable {
+		t.Skip("built without cgo, there is no SQLite")
+	}
+	file := filepath.Join(t.TempDir(), "scores.db")
+	db, err := OpenDatabase(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, file
+}
+
+// baselinePlayers is the database the game made before it had migrations, one table of names and scores
+func baselinePlayers(t *testing.T, db *sql.DB) {
+	t.Helper()
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS players(player_num INTEGER PRIMARY KEY, player_name TEXT NOT NULL, player_score INTEGER DEFAULT 0);",
+		"INSERT INTO players (player_name, player_score) VALUES ('Huy', 1200), ('Ana', 300), ('huy', 50), ('Ana', 0);",
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// dump lists every row of the table so two states of the database can be compared
+func dump(t *testing.T, db *sql.DB, query string) string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	var lines []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			t.Fatal(err)
+		}
+		var fields []string
+		for _, value := range values {
+			fields = append(fields, value.String)
+		}
+		lines = append(lines, strings.Join(fields, "|"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestMigrateBaselinePlayers(t *testing.T) {
+	db, file := openTestDatabase(t)
+	baselinePlayers(t, db)
+	ctx := context.Background()
+	store := NewSQLiteStore(db, file, randomScoreKey())
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	got := dump(t, db, "SELECT player_num, player_name, player_score, profiles.name FROM players JOIN profiles USING (profile_id) ORDER BY player_num")
+	want := "1|Huy|1200|Huy\n2|Ana|300|Ana\n3|huy|50|Huy\n4|Ana|0|Ana"
+	if got != want {
+		t.Fatalf("players after migrating:\n%s\nwant:\n%s", got, want)
+	}
+	if got := dump(t, db, "SELECT version FROM schema_version ORDER BY version"); got != "1\n2\n3\n4\n5\n6\n7\n8" {
+		t.Fatalf("schema versions %q", got)
+	}
+	if latest := migrations[len(migrations)-1].version; latest != 8 {
+		t.Fatalf("a migration was added, this test wants updating to %d", latest)
+	}
+	if problems, err := store.CheckSignatures(ctx); err != nil || len(problems) != 0 {
+		t.Fatalf("runs saved before signatures were not signed by the migration: %v %v", problems, err)
+	}
+	backups, err := listBackups(file)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("a database with scores in it is backed up before it is migrated: %v %v", backups, err)
+	}
+
+	before := dump(t, db, "SELECT * FROM players") + dump(t, db, "SELECT * FROM profiles") + dump(t, db, "SELECT version, name FROM schema_version")
+	applied, err := Migrate(ctx, db, map[int]migrationStep{signedSchema: store.signUnsigned})
+	if err != nil || applied != 0 {
+		t.Fatalf("second migrate applied %d: %v", applied, err)
+	}
+	after := dump(t, db, "SELECT * FROM players") + dump(t, db, "SELECT * FROM profiles") + dump(t, db, "SELECT version, name FROM schema_version")
+	if before != after {
+		t.Fatal("migrating an up to date database changed it")
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	db, _ := openTestDatabase(t)
+	baselinePlayers(t, db)
+	ctx := context.Background()
+	before := dump(t, db, "SELECT * FROM players")
+
+	failing := map[int]migrationStep{5: func(ctx context.Context, tx sqlConn) error {
+		return errors.New("disk full")
+	}}
+	applied, err := Migrate(ctx, db, failing)
+	if err == nil || !strings.Contains(err.Error(), "migration 5 (session mode): disk full") {
+		t.Fatalf("migrate: %d %v", applied, err)
+	}
+	if got := dump(t, db, "SELECT * FROM schema_version"); got != "" {
+		t.Fatalf("versions were recorded for a failed migration: %q", got)
+	}
+	for _, table := range []string{"settings", "profiles", "sessions", "profile_settings", "unlocks"} {
+		if tableExists(t, db, table) {
+			t.Errorf("table %s from a failed migration is still there", table)
+		}
+	}
+	if after := dump(t, db, "SELECT * FROM players"); after != before {
+		t.Fatalf("players changed by a failed migration:\n%s", after)
+	}
+
+	// the next start tries again from the beginning and gets there
+	if applied, err := Migrate(ctx, db, nil); err != nil || applied != len(migrations) {
+		t.Fatalf("retry applied %d: %v", applied, err)
+	}
+}
+
+func TestMigrateNewerDatabase(t *testing.T) {
+	db, _ := openTestDatabase(t)
+	ctx := context.Background()
+	if _, err := Migrate(ctx, db, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (99, 'from the future', '')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(ctx, db, nil); err == nil || !strings.Contains(err.Error(), "newer than this game understands") {
+		t.Fatalf("migrate: %v", err)
+	}
+}
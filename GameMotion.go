@@ -0,0 +1,201 @@
+package main
+
+import "math"
+
+// Action is a logical input the game reacts to, independent of the device that produced it
+type Action int
+
+const (
+	ActionUp Action = iota
+	ActionDown
+	ActionLeft
+	ActionRight
+	ActionDash
+	ActionShootUp
+	ActionShootDown
+	ActionShootLeft
+	ActionShootRight
+	ActionPause
+	ActionConfirm
+	ActionBack
+	ActionThrow // throw toward the aim point, used by the mouse control scheme
+	numActions
+)
+
+var actionNames = [numActions]string{
+	"Move Up", "Move Down", "Move Left", "Move Right", "Dash",
+	"Shoot Up", "Shoot Down", "Shoot Left", "Shoot Right",
+	"Pause", "Confirm", "Back", "Throw",
+}
+
+// InputFrame is the set of actions held down during one tick, plus where the player is aiming
+type InputFrame struct {
+	buttons uint32
+	aimX    int
+	aimY    int
+}
+
+func (frame InputFrame) Held(action Action) bool {
+	return frame.buttons&(1<<uint(action)) != 0
+}
+
+func (frame *InputFrame) Set(action Action) {
+	frame.buttons |= 1 << uint(action)
+}
+
+func (frame *InputFrame) merge(other InputFrame) {
+	frame.buttons |= other.buttons
+	if other.aimX != 0 || other.aimY != 0 {
+		frame.aimX, frame.aimY = other.aimX, other.aimY
+	}
+}
+
+// InputSource produces one InputFrame per tick
+type InputSource interface {
+	Poll() InputFrame
+}
+
+// multiInput merges several sources so keyboard and gamepads can be used at the same time
+type multiInput []InputSource
+
+func (sources multiInput) Poll() InputFrame {
+	var frame InputFrame
+	for _, source := range sources {
+		frame.merge(source.Poll())
+	}
+	return frame
+}
+
+type AccelCurve int
+
+const (
+	CurveInstant AccelCurve = iota // full speed on the first tick, stops dead on release
+	CurveLinear                    // speed changes by a fixed amount each tick
+	CurveEaseOut                   // speed closes a fraction of the gap each tick
+)
+
+var curveNames = []string{"Instant", "Linear", "Ease Out"}
+
+type MoveConfig struct {
+	maxSpeed     float64
+	curve        AccelCurve
+	acceleration float64 // per tick amount for CurveLinear, fraction of the gap for CurveEaseOut
+	friction     float64 // same as acceleration but used when no direction is held
+	dashSpeed    float64
+	dashTicks    int
+	dashCooldown int
+}
+
+func defaultMoveConfig() MoveConfig {
+	return MoveConfig{
+		maxSpeed:     5,
+		curve:        CurveInstant,
+		acceleration: 0.5,
+		friction:     0.75,
+		dashSpeed:    14,
+		dashTicks:    8,
+		dashCooldown: 45,
+	}
+}
+
+// Motion is the per-sprite state carried between ticks by stepMotion
+type Motion struct {
+	prev      InputFrame
+	pressedAt [numActions]int
+	vx, vy    float64
+	xFrac     float64
+	yFrac     float64
+	facingX   float64
+	facingY   float64
+	dashLeft  int
+	dashWait  int
+}
+
+func (motion *Motion) stop() {
+	motion.vx, motion.vy = 0, 0
+	motion.xFrac, motion.yFrac = 0, 0
+	motion.dashLeft = 0
+}
+
+// axis resolves a pair of opposite actions - when both are held the one pressed last wins
+func (motion *Motion) axis(frame InputFrame, negative Action, positive Action) float64 {
+	neg, pos := frame.Held(negative), frame.Held(positive)
+	if neg && pos {
+		if motion.pressedAt[positive] >= motion.pressedAt[negative] {
+			return 1
+		}
+		return -1
+	} else if pos {
+		return 1
+	} else if neg {
+		return -1
+	}
+	return 0
+}
+
+func approach(current float64, target float64, curve AccelCurve, rate float64) float64 {
+	switch curve {
+	case CurveLinear:
+		if current < target {
+			return math.Min(current+rate, target)
+		}
+		return math.Max(current-rate, target)
+	case CurveEaseOut:
+		next := current + (target-current)*rate
+		if math.Abs(target-next) < 0.05 {
+			return target
+		}
+		return next
+	}
+	return target
+}
+
+// stepMotion advances the motion by one tick of input and returns the whole pixels to move
+func stepMotion(motion *Motion, config MoveConfig, frame InputFrame, tick int) (dx int, dy int) {
+	for action := Action(0); action < numActions; action++ {
+		if frame.Held(action) && !motion.prev.Held(action) {
+			motion.pressedAt[action] = tick
+		}
+	}
+	dashPressed := frame.Held(ActionDash) && !motion.prev.Held(ActionDash)
+	motion.prev = frame
+
+	dirX := motion.axis(frame, ActionLeft, ActionRight)
+	dirY := motion.axis(frame, ActionUp, ActionDown)
+	if dirX != 0 && dirY != 0 { // keep diagonal speed the same as straight lines
+		dirX /= math.Sqrt2
+		dirY /= math.Sqrt2
+	}
+	if dirX != 0 || dirY != 0 {
+		motion.facingX, motion.facingY = dirX, dirY
+	}
+
+	if motion.dashWait > 0 {
+		motion.dashWait--
+	}
+	if dashPressed && motion.dashWait == 0 && (motion.facingX != 0 || motion.facingY != 0) {
+		motion.dashLeft = config.dashTicks
+		motion.dashWait = config.dashCooldown
+	}
+
+	if motion.dashLeft > 0 {
+		motion.dashLeft--
+		motion.vx = motion.facingX * config.dashSpeed
+		motion.vy = motion.facingY * config.dashSpeed
+	} else {
+		rate := config.acceleration
+		if dirX == 0 && dirY == 0 {
+			rate = config.friction
+		}
+		motion.vx = approach(motion.vx, dirX*config.maxSpeed, config.curve, rate)
+		motion.vy = approach(motion.vy, dirY*config.maxSpeed, config.curve, rate)
+	}
+
+	// carry the fractional part over so slow and diagonal speeds are not rounded away
+	motion.xFrac += motion.vx
+	motion.yFrac += motion.vy
+	dx, dy = int(motion.xFrac), int(motion.yFrac)
+	motion.xFrac -= float64(dx)
+	motion.yFrac -= float64(dy)
+	return dx, dy
+}
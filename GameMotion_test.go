@@ -0,0 +1,230 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// scriptedInput plays back a fixed list of frames, one per Poll, and holds nothing once it runs out
+type scriptedInput struct {
+	frames []InputFrame
+	next   int
+}
+
+func (script *scriptedInput) Poll() InputFrame {
+	if script.next >= len(script.frames) {
+		return InputFrame{}
+	}
+	script.next++
+	return script.frames[script.next-1]
+}
+
+func hold(actions ...Action) InputFrame {
+	var frame InputFrame
+	for _, action := range actions {
+		frame.Set(action)
+	}
+	return frame
+}
+
+// script strings frames together, each one held for the number of ticks that follows it
+func script(steps ...interface{}) []InputFrame {
+	var frames []InputFrame
+	for i := 0; i < len(steps); i += 2 {
+		for n := 0; n < steps[i+1].(int); n++ {
+			frames = append(frames, steps[i].(InputFrame))
+		}
+	}
+	return frames
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestStepMotion(t *testing.T) {
+	right, left, down := hold(ActionRight), hold(ActionLeft), hold(ActionDown)
+	tests := []struct {
+		name   string
+		curve  AccelCurve
+		frames []InputFrame
+		wantVX []float64
+		wantVY []float64
+	}{
+		{"instant starts and stops dead", CurveInstant, script(right, 2, InputFrame{}, 1),
+			[]float64{5, 5, 0}, nil},
+		{"linear speeds up and slows down a step at a time", CurveLinear, script(right, 3, InputFrame{}, 2),
+			[]float64{0.5, 1, 1.5, 0.75, 0}, nil},
+		{"linear tops out at max speed", CurveLinear, script(right, 12),
+			[]float64{0.5, 1, 1.5, 2, 2.5, 3, 3.5, 4, 4.5, 5, 5, 5}, nil},
+		{"ease out closes half the gap", CurveEaseOut, script(right, 3, InputFrame{}, 1),
+			[]float64{2.5, 3.75, 4.375, 4.375 * 0.25}, nil},
+		{"ease out snaps to the target when close", CurveEaseOut, script(InputFrame{}, 1),
+			[]float64{0}, nil},
+		{"the key pressed last wins", CurveInstant, script(right, 1, hold(ActionRight, ActionLeft), 2, right, 1),
+			[]float64{5, -5, -5, 5}, nil},
+		{"releasing one of two opposite keys keeps moving", CurveInstant, script(left, 1, hold(ActionLeft, ActionRight), 1, left, 1),
+			[]float64{-5, 5, -5}, nil},
+		{"opposite keys pressed together", CurveInstant, script(hold(ActionUp, ActionDown), 1),
+			[]float64{0}, []float64{5}},
+		{"diagonals are as fast as straight lines", CurveInstant, script(hold(ActionRight, ActionDown), 1),
+			[]float64{5 / math.Sqrt2}, []float64{5 / math.Sqrt2}},
+		{"down alone", CurveLinear, script(down, 2),
+			[]float64{0, 0}, []float64{0.5, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := defaultMoveConfig()
+			config.curve = test.curve
+			var motion Motion
+			for tick, frame := range test.frames {
+				stepMotion(&motion, config, frame, tick+1)
+				if tick < len(test.wantVX) && !near(motion.vx, test.wantVX[tick]) {
+					t.Errorf("tick %d: vx %v, want %v", tick, motion.vx, test.wantVX[tick])
+				}
+				if tick < len(test.wantVY) && !near(motion.vy, test.wantVY[tick]) {
+					t.Errorf("tick %d: vy %v, want %v", tick, motion.vy, test.wantVY[tick])
+				}
+			}
+		})
+	}
+}
+
+// speeds strings expected speeds together, each one followed by how many ticks it lasts
+func speeds(steps ...float64) []float64 {
+	var all []float64
+	for i := 0; i < len(steps); i += 2 {
+		for n := 0; n < int(steps[i+1]); n++ {
+			all = append(all, steps[i])
+		}
+	}
+	return all
+}
+
+func TestStepMotionDash(t *testing.T) {
+	config := defaultMoveConfig()
+	right, dash := hold(ActionRight), hold(ActionRight, ActionDash)
+	tests := []struct {
+		name   string
+		frames []InputFrame
+		wantVX []float64
+	}{
+		{"dash runs for dashTicks then back to walking", script(right, 1, dash, 1, right, 8), speeds(5, 1, 14, 8, 5, 1)},
+		{"holding dash does not dash again", script(right, 1, dash, 10), speeds(5, 1, 14, 8, 5, 2)},
+		{"no dash before the cooldown is over", script(right, 1, dash, 1, right, 10, dash, 1), speeds(5, 1, 14, 8, 5, 4)},
+		{"dash again after the cooldown", script(right, 1, dash, 1, right, config.dashCooldown, dash, 1),
+			speeds(5, 1, 14, 8, 5, float64(config.dashCooldown-config.dashTicks+1), 14, 1)},
+		{"a dog that has not moved yet has nowhere to dash", script(hold(ActionDash), 1), speeds(0, 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if len(test.frames) != len(test.wantVX) {
+				t.Fatalf("%d frames and %d speeds", len(test.frames), len(test.wantVX))
+			}
+			var motion Motion
+			for tick, frame := range test.frames {
+				stepMotion(&motion, config, frame, tick+1)
+				if !near(motion.vx, test.wantVX[tick]) {
+					t.Errorf("tick %d: vx %v, want %v", tick, motion.vx, test.wantVX[tick])
+				}
+			}
+		})
+	}
+}
+
+func TestStepMotionCarriesFractions(t *testing.T) {
+	config := defaultMoveConfig()
+	var motion Motion
+	x, y := 0, 0
+	for tick := 1; tick <= 100; tick++ {
+		dx, dy := stepMotion(&motion, config, hold(ActionRight, ActionDown), tick)
+		x, y = x+dx, y+dy
+	}
+	// 100 ticks at 5 pixels a tick, split evenly between the two axes
+	if want := int(math.Floor(500 / math.Sqrt2)); x != want || y != want {
+		t.Fatalf("moved %d,%d, want %d,%d", x, y, want, want)
+	}
+}
+
+// movementWorld is a level with nothing on it but the dog and whatever walls the test adds
+func movementWorld(walls ...Wall) *World {
+	world := &World{
+		difficulty: Difficulty{name: "Still", moveEvery: math.MaxInt32, shootEvery: math.MaxInt32},
+		moveConfig: defaultMoveConfig(),
+		numDogs:    1,
+	}
+	world.dogs[0].pict, world.dogs[0].Weapon.pict = pictureSize{50, 50}, pictureSize{20, 20}
+	for i := 0; i < numEnemies; i++ {
+		world.khaiSprite[i].pict, world.sophiaSprite[i].pict = pictureSize{50, 50}, pictureSize{50, 50}
+	}
+	world.startRun(1)
+	for i := 0; i < numEnemies; i++ { // out of the way, but still there so the level is not cleared
+		world.khaiSprite[i].xLoc, world.khaiSprite[i].yLoc = deadSprite, deadSprite
+		world.sophiaSprite[i].xLoc, world.sophiaSprite[i].yLoc = deadSprite, deadSprite
+	}
+	world.level[1].maxWall = len(walls)
+	copy(world.level[1].mazeWall[:], walls)
+	return world
+}
+
+func playScript(world *World, input InputSource, ticks int) {
+	for i := 0; i < ticks; i++ {
+		world.nextFrame(input.Poll())
+		if paused, _ := world.pauseTick(); paused {
+			continue
+		}
+		world.playTick()
+	}
+}
+
+func TestScriptedMovement(t *testing.T) {
+	right, up := hold(ActionRight), hold(ActionUp)
+	tests := []struct {
+		name      string
+		curve     AccelCurve
+		frames    []InputFrame
+		walls     []Wall
+		wantX     int
+		wantY     int
+		wantLives int
+	}{
+		{"walks right", CurveInstant, script(right, 10), nil, 450, 100, 3},
+		{"linear takes a while to get going", CurveLinear, script(right, 4), nil, 405, 100, 3},
+		{"stops when let go", CurveInstant, script(right, 2, InputFrame{}, 5), nil, 410, 100, 3},
+		{"dash covers more ground", CurveInstant, script(right, 1, hold(ActionRight, ActionDash), 1, right, 7), nil,
+			400 + 5 + 8*14, 100, 3},
+		{"paused ticks do not move", CurveInstant, script(right, 1, hold(ActionRight, ActionPause), 1, right, 5, hold(ActionPause), 1, right, 1),
+			nil, 410, 100, 3},
+		// the top edge is at the info bar, running into it costs a life and puts the dog back home
+		{"the edge of the screen sends the dog home", CurveInstant, script(up, 10), nil, xStart, yStart, 2},
+		{"a maze wall sends the dog home", CurveInstant, script(right, 7),
+			[]Wall{{xLoc: 480, yLoc: 60, pict: pictureSize{WallThickness, 200}}}, xStart, yStart, 2},
+		{"walking along a wall is fine", CurveInstant, script(right, 10),
+			[]Wall{{xLoc: 300, yLoc: 200, pict: pictureSize{400, WallThickness}}}, 450, 100, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			world := movementWorld(test.walls...)
+			world.moveConfig.curve = test.curve
+			playScript(world, &scriptedInput{frames: test.frames}, len(test.frames))
+			dog := world.dogs[0]
+			if dog.xLoc != test.wantX || dog.yLoc != test.wantY || dog.lives != test.wantLives {
+				t.Fatalf("dog at %d,%d with %d lives, want %d,%d with %d", dog.xLoc, dog.yLoc, dog.lives,
+					test.wantX, test.wantY, test.wantLives)
+			}
+			if dog.lives < 3 && (dog.motion.vx != 0 || dog.motion.vy != 0 || world.score != -100) {
+				t.Fatalf("sent home still moving at %v,%v with score %d", dog.motion.vx, dog.motion.vy, world.score)
+			}
+		})
+	}
+}
+
+func TestEnemyAcrossTwoWallsScoresOnce(t *testing.T) {
+	// the khai's top left corner is in one wall and its bottom right corner in the other
+	world := movementWorld(Wall{xLoc: 90, yLoc: 290, pict: pictureSize{20, 20}}, Wall{xLoc: 140, yLoc: 340, pict: pictureSize{20, 20}})
+	world.khaiSprite[0].xLoc, world.khaiSprite[0].yLoc = 100, 300
+	hitMaze(world)
+	if world.khaiSprite[0].alive || world.score != khaiValue/2 {
+		t.Fatalf("khai alive %t, score %d, want gone and %d", world.khaiSprite[0].alive, world.score, khaiValue/2)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	netFormat     = "pupperooo-net-1"
+	netPort       = "7777"
+	netInputDelay = 3 // ticks between reading a player's input and playing it, time for it to reach the other machine
+	netTimeout    = 10 * time.Second
+)
+
+// NetStart is what both machines agree on before an online run, the host decides it
+type NetStart struct {
+	seed         int64
+	difficulty   string
+	curve        AccelCurve
+	aimWithMouse bool
+	delay        int
+}
+
+func newNetStart(world *World) NetStart {
+	return NetStart{seed: time.Now().UnixNano(), difficulty: world.difficulty.name, curve: world.moveConfig.curve,
+		aimWithMouse: world.aimWithMouse, delay: netInputDelay}
+}
+
+// apply sets the world up for the run, the rules have to be the same on both machines
+func (start NetStart) apply(world *World) {
+	world.numDogs = 2
+	world.difficulty = difficultyNamed(start.difficulty)
+	world.moveConfig.curve = start.curve
+	world.aimWithMouse = start.aimWithMouse
+}
+
+// NetGame is one machine's side of an online co-op run. The machines only send each other the input of every tick,
+// each plays the whole run itself and a tick is only played once both inputs for it are in, so the two stay in
+// lockstep. A hash of the world before every tick is sent as well, so machines that drift apart find out.
+type NetGame struct {
+	conn  net.Conn
+	out   *bufio.Writer
+	local int // the dog this machine plays, the host is player one
+	start NetStart
+	tick  int // the next tick to play, changed with the lock held as read checks the other machine's ticks against it
+	sent  int // this machine's input has gone out for every tick before this one
+
+	localFrames  map[int]InputFrame
+	localHashes  map[int]uint64
+	waitingSince time.Time
+	writeErr     error
+
+	lock         sync.Mutex
+	remoteFrames map[int]InputFrame
+	remoteHashes map[int]uint64
+	remoteSent   int // the tick of the other machine's next input
+	remoteHashed int // the tick of the other machine's next hash
+	readErr      error
+}
+
+func newNetGame(conn net.Conn, in *bufio.Reader, local int, start NetStart) *NetGame {
+	game := &NetGame{
+		conn:         conn,
+		out:          bufio.NewWriter(conn),
+		local:        local,
+		start:        start,
+		sent:         start.delay,
+		localFrames:  map[int]InputFrame{},
+		localHashes:  map[int]uint64{},
+		remoteFrames: map[int]InputFrame{},
+		remoteHashes: map[int]uint64{},
+		remoteSent:   start.delay,
+	}
+	// nobody has pressed anything before the run starts
+	for tick := 0; tick < start.delay; tick++ {
+		game.localFrames[tick] = InputFrame{}
+		game.remoteFrames[tick] = InputFrame{}
+	}
+	go game.read(in)
+	return game
+}
+
+// hostNetGame waits for a player to join on the listener and tells them how the run starts
+func hostNetGame(listener net.Listener, start NetStart, version string) (*NetGame, error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(netTimeout))
+	in := bufio.NewReader(conn)
+	line, err := in.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("the other player did not say hello: %w", err)
+	}
+	var format, theirs string
+	if _, err := fmt.Sscanf(line, "%s hello %q", &format, &theirs); err != nil || format != netFormat {
+		conn.Close()
+		return nil, errors.New("someone who is not playing this game tried to join")
+	}
+	if theirs != version {
+		fmt.Fprintf(conn, "refused %q\n", "the host has version "+version)
+		conn.Close()
+		return nil, fmt.Errorf("a player with version %s tried to join, this is %s", theirs, version)
+	}
+	_, err = fmt.Fprintf(conn, "start %d %q %q %t %d\n", start.seed, start.difficulty, curveNames[start.curve], start.aimWithMouse, start.delay)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return newNetGame(conn, in, 0, start), nil
+}
+
+// joinNetGame connects to a host and waits to hear how the run starts
+func joinNetGame(ctx context.Context, address string, version string) (*NetGame, error) {
+	if !strings.Contains(address, ":") {
+		address += ":" + netPort
+	}
+	dialer := net.Dialer{Timeout: netTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(netTimeout))
+	if _, err := fmt.Fprintf(conn, "%s hello %q\n", netFormat, version); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	in := bufio.NewReader(conn)
+	line, err := in.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("the host did not answer: %w", err)
+	}
+	var reason string
+	if _, err := fmt.Sscanf(line, "refused %q", &reason); err == nil {
+		conn.Close()
+		return nil, errors.New("the host turned us down: " + reason)
+	}
+	var start NetStart
+	var curve string
+	_, err = fmt.Sscanf(line, "start %d %q %q %t %d", &start.seed, &start.difficulty, &curve, &start.aimWithMouse, &start.delay)
+	start.curve = -1
+	for i := range curveNames {
+		if curveNames[i] == curve {
+			start.curve = AccelCurve(i)
+		}
+	}
+	if err != nil || start.curve < 0 || start.delay < 1 || difficultyNamed(start.difficulty).name != start.difficulty {
+		conn.Close()
+		return nil, fmt.Errorf("the host sent a start this game does not understand: %q", strings.TrimSpace(line))
+	}
+	conn.SetDeadline(time.Time{})
+	return newNetGame(conn, in, 1, start), nil
+}
+
+// read takes in the other machine's inputs and hashes until the connection ends. Each comes once for every tick
+// in order, and no further ahead than the other machine can be in lockstep: playing up to delay+1 ticks ahead
+// and sending input delay ticks ahead of that. Anything else ends the run and hangs up, rather than letting
+// the other machine rewrite ticks already played or fill memory with ticks far in the future.
+func (game *NetGame) read(in *bufio.Reader) {
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			game.lock.Lock()
+			game.readErr = errors.New("the other player left")
+			game.lock.Unlock()
+			return
+		}
+		var tick int
+		var frame InputFrame
+		var hash uint64
+		var bad error
+		game.lock.Lock()
+		ahead := game.tick + 2*game.start.delay + 1
+		if _, err := fmt.Sscanf(line, "input %d %d %d %d", &tick, &frame.buttons, &frame.aimX, &frame.aimY); err == nil {
+			if tick != game.remoteSent || tick > ahead {
+				bad = fmt.Errorf("the other player sent input for tick %d while this machine plays tick %d", tick, game.tick)
+			} else {
+				game.remoteFrames[tick] = frame
+				game.remoteSent++
+			}
+		} else if _, err := fmt.Sscanf(line, "hash %d %x", &tick, &hash); err == nil {
+			if tick != game.remoteHashed || tick > ahead {
+				bad = fmt.Errorf("the other player sent a hash for tick %d while this machine plays tick %d", tick, game.tick)
+			} else {
+				game.remoteHashes[tick] = hash
+				game.remoteHashed++
+			}
+		}
+		if bad != nil {
+			game.readErr = bad
+		}
+		game.lock.Unlock()
+		if bad != nil {
+			game.conn.Close()
+			return
+		}
+	}
+}
+
+// Next sends this machine's input and returns the input of both dogs for the next tick, in dog order.
+// ready is false while the other machine's input for the tick has not come in yet, the game waits a frame and asks again.
+// hash is the world as it is before the tick, it is compared with the other machine's.
+func (game *NetGame) Next(local InputFrame, hash uint64) (frames [maxDogs]InputFrame, ready bool, err error) {
+	// local input goes out delay ticks ahead of the tick being played, so it has time to reach the other machine
+	if game.sent <= game.tick+game.start.delay {
+		game.localFrames[game.sent] = local
+		fmt.Fprintf(game.out, "input %d %d %d %d\n", game.sent, local.buttons, local.aimX, local.aimY)
+		game.sent++
+		game.flush()
+	}
+
+	game.lock.Lock()
+	remote, ok := game.remoteFrames[game.tick]
+	readErr := game.readErr
+	game.lock.Unlock()
+	if !ok {
+		// a machine that finished the run first has hung up, its input up to the end is still here to play
+		if readErr != nil {
+			return frames, false, readErr
+		}
+		if game.writeErr != nil {
+			return frames, false, game.writeErr
+		}
+		if game.waitingSince.IsZero() {
+			game.waitingSince = time.Now()
+		} else if time.Since(game.waitingSince) > netTimeout {
+			return frames, false, errors.New("the other player stopped answering")
+		}
+		return frames, false, nil
+	}
+	game.waitingSince = time.Time{}
+
+	frames[game.local] = game.localFrames[game.tick]
+	frames[1-game.local] = remote
+	game.localHashes[game.tick] = hash
+	fmt.Fprintf(game.out, "hash %d %x\n", game.tick, hash)
+	delete(game.localFrames, game.tick)
+	game.lock.Lock()
+	delete(game.remoteFrames, game.tick)
+	game.tick++
+	game.lock.Unlock()
+	game.flush()
+	return frames, true, game.checkHashes()
+}
+
+func (game *NetGame) flush() {
+	game.conn.SetWriteDeadline(time.Now().Add(netTimeout))
+	if err := game.out.Flush(); err != nil && game.writeErr == nil {
+		game.writeErr = errors.New("the other player left")
+	}
+}
+
+// checkHashes compares the hashes both machines have sent so far, any difference means the runs have drifted apart
+func (game *NetGame) checkHashes() error {
+	game.lock.Lock()
+	defer game.lock.Unlock()
+	for tick, hash := range game.localHashes {
+		remote, ok := game.remoteHashes[tick]
+		if !ok {
+			continue
+		}
+		if remote != hash {
+			return fmt.Errorf("out of sync with the other player at tick %d", tick)
+		}
+		delete(game.localHashes, tick)
+		delete(game.remoteHashes, tick)
+	}
+	return nil
+}
+
+// Waiting is how long the game has been waiting on the other player's input
+func (game *NetGame) Waiting() time.Duration {
+	if game.waitingSince.IsZero() {
+		return 0
+	}
+	return time.Since(game.waitingSince)
+}
+
+func (game *NetGame) Close() error {
+	return game.conn.Close()
+}
+
+// stateHash sums up everything in the world the rules move. Two machines that played the same ticks
+// have the same hash, unless something other than the input has crept into the rules.
+func (world *World) stateHash() uint64 {
+	hash := fnv.New64a()
+	var buf [8]byte
+	write := func(values ...int) {
+		for _, value := range values {
+			binary.LittleEndian.PutUint64(buf[:], uint64(value))
+			hash.Write(buf[:])
+		}
+	}
+	flag := func(value bool) int {
+		if value {
+			return 1
+		}
+		return 0
+	}
+	write(world.counter, world.currentLevel, world.score, flag(world.paused), world.pauseCursor)
+	for i := 0; i < world.numDogs; i++ {
+		dog := world.dogs[i]
+		write(dog.xLoc, dog.yLoc, dog.lives, dog.score, flag(dog.alive), flag(dog.activeShot), dog.Weapon.dx, dog.Weapon.dy)
+		write(int(math.Float64bits(dog.motion.vx)), int(math.Float64bits(dog.motion.vy)))
+	}
+	for i := 0; i < numEnemies; i++ {
+		for _, enemy := range [...]Sprite{world.khaiSprite[i], world.sophiaSprite[i]} {
+			write(enemy.xLoc, enemy.yLoc, enemy.lives, flag(enemy.alive), flag(enemy.activeShot), enemy.Weapon.dx, enemy.Weapon.dy)
+		}
+	}
+	return hash.Sum64()
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectPeers hosts a run on a loopback port and joins it
+func connectPeers(t *testing.T, hostVersion string, joinVersion string, delay int) (host *NetGame, join *NetGame, hostErr error, joinErr error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	start := NetStart{seed: 99, difficulty: "Hard", curve: CurveEaseOut, delay: delay}
+	hosted := make(chan error, 1)
+	go func() {
+		host, err = hostNetGame(listener, start, hostVersion)
+		hosted <- err
+	}()
+	join, joinErr = joinNetGame(context.Background(), listener.Addr().String(), joinVersion)
+	hostErr = <-hosted
+	for _, game := range []*NetGame{host, join} {
+		if game != nil {
+			game := game
+			t.Cleanup(func() { game.Close() })
+		}
+	}
+	return host, join, hostErr, joinErr
+}
+
+// peerRun is what one machine did in a run played without a world
+type peerRun struct {
+	sent   map[int]InputFrame // the input handed to Next on the call that sent it, by the tick it is for
+	played [][maxDogs]InputFrame
+	err    error
+}
+
+// playPeer plays one machine's side for ticks ticks pressing made up buttons, hash is the world's hash before a tick.
+// A machine that gets an error hangs up, the way the game ends an online run.
+func playPeer(game *NetGame, ticks int, hash func(tick int) uint64) peerRun {
+	run := peerRun{sent: map[int]InputFrame{}}
+	buttons := rand.New(rand.NewSource(int64(game.local) + 1))
+	for len(run.played) < ticks {
+		local := InputFrame{buttons: uint32(buttons.Intn(1 << 10)), aimX: buttons.Intn(ScreenWidth)}
+		sending := game.sent
+		frames, ready, err := game.Next(local, hash(len(run.played)))
+		if game.sent > sending {
+			run.sent[sending] = local
+		}
+		if err != nil {
+			run.err = err
+			game.Close()
+			break
+		}
+		if !ready {
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+		run.played = append(run.played, frames)
+	}
+	return run
+}
+
+// playPeers plays both sides at once
+func playPeers(host *NetGame, join *NetGame, ticks [2]int, hashes [2]func(tick int) uint64) (peerRun, peerRun) {
+	hosted := make(chan peerRun, 1)
+	go func() { hosted <- playPeer(host, ticks[0], hashes[0]) }()
+	joined := playPeer(join, ticks[1], hashes[1])
+	return <-hosted, joined
+}
+
+func tickHash(tick int) uint64 { return uint64(tick) }
+
+func TestNetJoin(t *testing.T) {
+	host, join, hostErr, joinErr := connectPeers(t, "1.1.0", "1.1.0", 4)
+	if hostErr != nil || joinErr != nil {
+		t.Fatal(hostErr, joinErr)
+	}
+	if host.local != 0 || join.local != 1 || join.start != host.start {
+		t.Fatalf("host plays dog %d and joiner dog %d, joiner starts %+v, host %+v", host.local, join.local, join.start, host.start)
+	}
+}
+
+func TestNetRefusesAnotherVersion(t *testing.T) {
+	_, _, hostErr, joinErr := connectPeers(t, "1.1.0", "1.0.0", netInputDelay)
+	if hostErr == nil || !strings.Contains(hostErr.Error(), "version 1.0.0 tried to join") {
+		t.Fatalf("host: %v", hostErr)
+	}
+	if joinErr == nil || joinErr.Error() != "the host turned us down: the host has version 1.1.0" {
+		t.Fatalf("joiner: %v", joinErr)
+	}
+}
+
+func TestNetLockstep(t *testing.T) {
+	for _, delay := range []int{1, netInputDelay} {
+		t.Run(fmt.Sprintf("delay %d", delay), func(t *testing.T) {
+			host, join, hostErr, joinErr := connectPeers(t, "1.1.0", "1.1.0", delay)
+			if hostErr != nil || joinErr != nil {
+				t.Fatal(hostErr, joinErr)
+			}
+			hosted, joined := playPeers(host, join, [2]int{300, 300}, [2]func(int) uint64{tickHash, tickHash})
+			if hosted.err != nil || joined.err != nil {
+				t.Fatal(hosted.err, joined.err)
+			}
+			for tick := range hosted.played {
+				want := [maxDogs]InputFrame{hosted.sent[tick], joined.sent[tick]}
+				if tick < delay {
+					want = [maxDogs]InputFrame{} // nobody pressed anything before the run
+				}
+				if hosted.played[tick] != want || joined.played[tick] != want {
+					t.Fatalf("tick %d: host played %v and joiner %v, want the input sent %d ticks before, %v",
+						tick, hosted.played[tick], joined.played[tick], delay, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNetOutOfSync(t *testing.T) {
+	host, join, hostErr, joinErr := connectPeers(t, "1.1.0", "1.1.0", netInputDelay)
+	if hostErr != nil || joinErr != nil {
+		t.Fatal(hostErr, joinErr)
+	}
+	drifted := func(tick int) uint64 {
+		if tick >= 50 {
+			return uint64(tick) + 1
+		}
+		return uint64(tick)
+	}
+	hosted, joined := playPeers(host, join, [2]int{300, 300}, [2]func(int) uint64{tickHash, drifted})
+	found := false
+	for _, run := range []peerRun{hosted, joined} {
+		if run.err == nil {
+			t.Fatal("a machine played on after the runs drifted apart")
+		}
+		if run.err.Error() == "out of sync with the other player at tick 50" {
+			found = true
+			if len(run.played) > 50+2*netInputDelay+2 {
+				t.Fatalf("found the drift %d ticks late", len(run.played)-50)
+			}
+		}
+	}
+	if !found {
+		t.Fatal(hosted.err, joined.err)
+	}
+}
+
+func TestNetPeerLeaves(t *testing.T) {
+	host, join, hostErr, joinErr := connectPeers(t, "1.1.0", "1.1.0", netInputDelay)
+	if hostErr != nil || joinErr != nil {
+		t.Fatal(hostErr, joinErr)
+	}
+	hosted := make(chan peerRun, 1)
+	go func() {
+		run := playPeer(host, 100, tickHash)
+		host.Close()
+		hosted <- run
+	}()
+	joined := playPeer(join, 300, tickHash)
+	<-hosted
+	if joined.err == nil || joined.err.Error() != "the other player left" {
+		t.Fatalf("%v", joined.err)
+	}
+	// the host's input was still there to play up to where it left
+	if len(joined.played) < 100 || len(joined.played) > 100+netInputDelay+1 {
+		t.Fatalf("the joiner played %d ticks of a run the host left at 100", len(joined.played))
+	}
+}
+
+func TestNetPeerStopsAnswering(t *testing.T) {
+	_, join, hostErr, joinErr := connectPeers(t, "1.1.0", "1.1.0", netInputDelay)
+	if hostErr != nil || joinErr != nil {
+		t.Fatal(hostErr, joinErr)
+	}
+	// the host is connected but never plays, the joiner gets through the ticks before the run and then waits
+	for tick := 0; tick < netInputDelay; tick++ {
+		if _, ready, err := join.Next(InputFrame{}, 0); !ready || err != nil {
+			t.Fatalf("tick %d: %t %v", tick, ready, err)
+		}
+	}
+	if _, ready, err := join.Next(InputFrame{}, 0); ready || err != nil {
+		t.Fatalf("waiting for the host: %t %v", ready, err)
+	}
+	join.waitingSince = time.Now().Add(-netTimeout - time.Second)
+	if _, _, err := join.Next(InputFrame{}, 0); err == nil || err.Error() != "the other player stopped answering" {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestNetRejectsTicksOutOfStep(t *testing.T) {
+	const delay = 3
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"a tick before the run", []string{"input 1 0 0 0"}, "input for tick 1 while this machine plays tick 0"},
+		{"the same tick twice", []string{"input 3 0 0 0", "input 3 8 0 0"}, "input for tick 3 "},
+		{"a tick skipped", []string{"input 4 0 0 0"}, "input for tick 4 "},
+		// the other machine plays at most delay ticks ahead and sends its input delay ticks ahead of that
+		{"further ahead than lockstep allows", lines("input %d 0 0 0", delay, 2*delay+2), "input for tick 8 "},
+		{"a hash skipped", []string{"hash 1 ff"}, "a hash for tick 1 "},
+		{"a hash far ahead", lines("hash %d ff", 0, 2*delay+2), "a hash for tick 8 "},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			game, other := pipedNetGame(t, delay)
+			for _, line := range test.lines {
+				if _, err := io.WriteString(other, line+"\n"); err != nil {
+					t.Fatalf("writing %q: %v", line, err)
+				}
+			}
+			err := readError(game)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("%v, want %q", err, test.want)
+			}
+			if _, err := io.WriteString(other, "input 3 0 0 0\n"); err == nil {
+				t.Fatal("still connected")
+			}
+		})
+	}
+
+	t.Run("in step", func(t *testing.T) {
+		game, other := pipedNetGame(t, delay)
+		for tick := delay; tick <= 2*delay+1; tick++ {
+			fmt.Fprintf(other, "input %d %d 0 0\nhash %d 0\n", tick, tick, tick-delay)
+		}
+		for tick := 0; tick <= 2*delay+1; {
+			frames, ready, err := game.Next(InputFrame{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ready {
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			if want := (InputFrame{buttons: uint32(tick)}); tick >= delay && frames[1] != want {
+				t.Fatalf("tick %d played %v, want %v", tick, frames[1], want)
+			}
+			tick++
+		}
+	})
+}
+
+// lines is format filled in with every tick from first up to last
+func lines(format string, first int, last int) []string {
+	var lines []string
+	for tick := first; tick <= last; tick++ {
+		lines = append(lines, fmt.Sprintf(format, tick))
+	}
+	return lines
+}
+
+// pipedNetGame is the host's side of a run over a pipe, the test plays the other machine on the returned end
+func pipedNetGame(t *testing.T, delay int) (*NetGame, net.Conn) {
+	local, other := net.Pipe()
+	game := newNetGame(local, bufio.NewReader(local), 0, NetStart{seed: 1, difficulty: "Normal", delay: delay})
+	go io.Copy(ioutil.Discard, other) // what the game sends
+	t.Cleanup(func() {
+		game.Close()
+		other.Close()
+	})
+	return game, other
+}
+
+// readError waits a moment for read to give up on the connection
+func readError(game *NetGame) error {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		game.lock.Lock()
+		err := game.readErr
+		game.lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
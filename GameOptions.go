@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/colornames"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// actions the player is allowed to rebind, confirm and back stay fixed so the menus always work
+var rebindableActions = [...]Action{
+	ActionUp, ActionDown, ActionLeft, ActionRight, ActionDash,
+	ActionShootUp, ActionShootDown, ActionShootLeft, ActionShootRight, ActionPause,
+}
+
+const (
+	optionMovement = len(rebindableActions) + iota
+	optionAiming
+	optionDifficulty
+	optionDefaults
+	optionDone
+	numOptionRows
+)
+
+func settingName(action Action) string {
+	return "key." + strings.ReplaceAll(strings.ToLower(actionNames[action]), " ", "_")
+}
+
+func settingsFromGame(game *Game) map[string]string {
+	settings := make(map[string]string)
+	for _, action := range rebindableActions {
+		settings[settingName(action)] = game.bindings.encode(action)
+	}
+	settings["move.curve"] = curveNames[game.moveConfig.curve]
+	settings["aim.scheme"] = aimSchemeName(game.aimWithMouse)
+	settings["game.difficulty"] = game.difficulty.name
+	return settings
+}
+
+// applySettings resets the controls to their defaults and then applies whatever the player saved
+func applySettings(game *Game, settings map[string]string) {
+	for action, keys := range defaultBindings() {
+		game.bindings[action] = keys
+	}
+	for _, action := range rebindableActions {
+		if value, ok := settings[settingName(action)]; ok {
+			var keys []ebiten.Key
+			for _, key := range decodeKeys(value) { // saved before the menu keys were kept back
+				if !game.bindings.menuKey(key) {
+					keys = append(keys, key)
+				}
+			}
+			if len(keys) > 0 {
+				game.bindings[action] = keys
+			}
+		}
+	}
+	game.moveConfig.curve = CurveInstant
+	for i := range curveNames {
+		if settings["move.curve"] == curveNames[i] {
+			game.moveConfig.curve = AccelCurve(i)
+		}
+	}
+	game.aimWithMouse = settings["aim.scheme"] == aimSchemeName(true)
+	game.difficulty = difficultyNamed(settings["game.difficulty"])
+}
+
+func aimSchemeName(mouse bool) string {
+	if mouse {
+		return "Mouse"
+	}
+	return "Keys"
+}
+
+func openOptions(game *Game) {
+	loadProfile(game, "Loading your settings", func() {
+		game.optionsOpen = true
+		game.optionsCursor = 0
+		game.rebinding = false
+	})
+}
+
+func closeOptions(game *Game) {
+	profileID, settings := game.profile.id, settingsFromGame(game)
+	queueWrite(game, "settings "+strconv.Itoa(profileID), "save settings", func(ctx context.Context, store ScoreStore) error {
+		return store.SaveSettings(ctx, profileID, settings)
+	})
+	game.optionsOpen = false
+}
+
+func optionsMenu(game *Game) {
+	if game.rebinding { // waiting for the new key, escape gives up
+		for key := ebiten.Key(0); key <= ebiten.KeyMax; key++ {
+			if inpututil.IsKeyJustPressed(key) {
+				if key != ebiten.KeyEscape {
+					if err := game.bindings.rebind(rebindableActions[game.optionsCursor], key); err != nil {
+						game.notice = err.Error()
+						game.noticeTicks = 120
+					}
+				}
+				game.rebinding = false
+				break
+			}
+		}
+		// the key that finished rebinding should not also act as menu input
+		game.dogs[0].frame = game.input.Poll()
+		game.dogs[0].prevFrame = game.dogs[0].frame
+		return
+	}
+
+	if game.justPressed(ActionUp) && game.optionsCursor > 0 {
+		game.optionsCursor--
+	} else if game.justPressed(ActionDown) && game.optionsCursor < numOptionRows-1 {
+		game.optionsCursor++
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) || game.justPressed(ActionBack) {
+		closeOptions(game)
+	} else if game.justPressed(ActionConfirm) {
+		switch game.optionsCursor {
+		case optionMovement:
+			game.moveConfig.curve = (game.moveConfig.curve + 1) % AccelCurve(len(curveNames))
+		case optionAiming:
+			game.aimWithMouse = !game.aimWithMouse
+		case optionDifficulty:
+			for i := range difficulties {
+				if difficulties[i].name == game.difficulty.name {
+					game.difficulty = difficulties[(i+1)%len(difficulties)]
+					break
+				}
+			}
+		case optionDefaults:
+			applySettings(game, map[string]string{})
+		case optionDone:
+			closeOptions(game)
+		default:
+			game.rebinding = true
+		}
+	}
+}
+
+func (game Game) drawOptions(screen *ebiten.Image) {
+	optionsFont := makeFont(16, 72)
+	text.Draw(screen, "Options for "+game.profile.name, makeFont(30, 72), 50, 260, colornames.Tomato)
+	for row := 0; row < numOptionRows; row++ {
+		label, value := "", ""
+		switch row {
+		case optionMovement:
+			label, value = "Movement", curveNames[game.moveConfig.curve]
+		case optionAiming:
+			label, value = "Aiming", aimSchemeName(game.aimWithMouse)
+		case optionDifficulty:
+			label, value = "Difficulty", game.difficulty.name
+		case optionDefaults:
+			label = "Reset to defaults"
+		case optionDone:
+			label = "Save and go back"
+		default:
+			action := rebindableActions[row]
+			label, value = actionNames[action], game.bindings.describe(action)
+			if game.rebinding && row == game.optionsCursor {
+				value = "press a key... (Esc cancels)"
+			}
+		}
+		rowColor := color.Color(color.White)
+		if row == game.optionsCursor {
+			rowColor = colornames.Yellow
+		}
+		yAxis := 310 + 27*row
+		text.Draw(screen, label, optionsFont, 80, yAxis, rowColor)
+		text.Draw(screen, value, optionsFont, 380, yAxis, rowColor)
+	}
+	text.Draw(screen, "Enter to change, Tab or Backspace to save and go back", makeFont(14, 72), 80, ScreenHeight-30, color.White)
+}
+
+// controlsText builds the control list for the instructions from the bindings currently in use
+func controlsText(bindings Bindings) string {
+	line := func(actions ...Action) string {
+		var parts []string
+		for _, action := range actions {
+			parts = append(parts, bindings.describe(action)+" - "+strings.ToUpper(actionNames[action]))
+		}
+		return "        " + strings.Join(parts, "      ") + "\n"
+	}
+	return "\n** Controls ** - on the keyboard press the following, TAB on this screen changes them, F1 shows your profile and F2 the high scores\n" +
+		line(ActionShootUp, ActionShootDown, ActionShootLeft, ActionShootRight) +
+		line(ActionUp, ActionDown, ActionLeft, ActionRight) +
+		line(ActionDash, ActionPause) +
+		"        with Aiming set to Mouse in the options, LEFT CLICK throws toward the crosshair\n" +
+		"    A gamepad works too - left stick or d-pad to move, right stick or face buttons to throw, bumpers to dash, START to pause\n" +
+		"    F3 switches to two players - player two moves with IJKL, throws with numpad 8 4 5 6, dashes with numpad 0 or O, or uses the second gamepad\n" +
+		"    F4 plays co-op online - one of you hosts and the other joins with the host's address"
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Submission is a finished run waiting in the outbox until the leaderboard server has it
+type Submission struct {
+	key       string // idempotency key, a server that already has the run answers again without saving it twice
+	body      string // the POST /scores body
+	attempts  int
+	nextTry   time.Time
+	lastError string
+}
+
+// QueueSubmission puts a run in the outbox, queueing the same key twice keeps the first
+func (store *SQLiteStore) QueueSubmission(ctx context.Context, submission Submission) error {
+	statement := "INSERT OR IGNORE INTO outbox (idempotency_key, body, attempts, next_try, last_error) VALUES (?, ?, ?, ?, ?)"
+	_, err := store.conn().ExecContext(ctx, statement, submission.key, submission.body, submission.attempts,
+		submission.nextTry.UTC().Format(time.RFC3339), submission.lastError)
+	if err != nil {
+		return fmt.Errorf("queue submission: %w", err)
+	}
+	return nil
+}
+
+// DueSubmissions returns the runs in the outbox that are due another try, the longest waiting first
+func (store *SQLiteStore) DueSubmissions(ctx context.Context, now time.Time) ([]Submission, error) {
+	statement := "SELECT idempotency_key, body, attempts, next_try, last_error FROM outbox WHERE next_try <= ? ORDER BY next_try, queued_at"
+	rows, err := store.conn().QueryContext(ctx, statement, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("due submissions: %w", err)
+	}
+	defer rows.Close()
+	var due []Submission
+	for rows.Next() {
+		var submission Submission
+		var nextTry string
+		if err := rows.Scan(&submission.key, &submission.body, &submission.attempts, &nextTry, &submission.lastError); err != nil {
+			return nil, fmt.Errorf("due submissions: %w", err)
+		}
+		submission.nextTry, _ = time.Parse(time.RFC3339, nextTry)
+		due = append(due, submission)
+	}
+	return due, rows.Err()
+}
+
+// RetrySubmission saves when a run that could not be posted gets tried again and why it failed
+func (store *SQLiteStore) RetrySubmission(ctx context.Context, submission Submission) error {
+	statement := "UPDATE outbox SET attempts = ?, next_try = ?, last_error = ? WHERE idempotency_key = ?"
+	_, err := store.conn().ExecContext(ctx, statement, submission.attempts, submission.nextTry.UTC().Format(time.RFC3339),
+		submission.lastError, submission.key)
+	if err != nil {
+		return fmt.Errorf("retry submission: %w", err)
+	}
+	return nil
+}
+
+// RemoveSubmission takes a run out of the outbox once the server has answered it for good
+func (store *SQLiteStore) RemoveSubmission(ctx context.Context, key string) error {
+	if _, err := store.conn().ExecContext(ctx, "DELETE FROM outbox WHERE idempotency_key = ?", key); err != nil {
+		return fmt.Errorf("remove submission: %w", err)
+	}
+	return nil
+}
+
+// SubmissionAnswer is what the server answered the first time it saw an idempotency key, false for a new key
+func (store *SQLiteStore) SubmissionAnswer(ctx context.Context, key string) (string, bool, error) {
+	var answer string
+	err := store.conn().QueryRowContext(ctx, "SELECT answer FROM received_submissions WHERE idempotency_key = ?", key).Scan(&answer)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("submission answer: %w", err)
+	}
+	return answer, true, nil
+}
+
+func (store *SQLiteStore) SaveSubmissionAnswer(ctx context.Context, key string, answer string) error {
+	statement := "INSERT OR REPLACE INTO received_submissions (idempotency_key, answer) VALUES (?, ?)"
+	if _, err := store.conn().ExecContext(ctx, statement, key, answer); err != nil {
+		return fmt.Errorf("save submission answer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	dbEnvVar   = "PUPPEROOO_DB"
+	appDirName = "RunPupperooo"
+)
+
+// userDataDir is where each OS expects programs to keep a user's data
+func userDataDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return dir, nil
+		}
+		return os.UserConfigDir()
+	case "darwin":
+		home, err := os.UserHomeDir()
+		return filepath.Join(home, "Library", "Application Support"), err
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share"), err
+}
+
+// databasePath picks the file the store keeps its data in: the -db flag, then $PUPPEROOO_DB, then the user data dir.
+// The first time the user data dir is used, a database left in the working directory by older versions is copied over.
+// When something goes wrong it still returns a file to use, the one in the working directory like older versions did.
+func databasePath(flagValue string, backend string) (string, error) {
+	file := flagValue
+	if file == "" {
+		file = os.Getenv(dbEnvVar)
+	}
+	if file != "" {
+		return file, os.MkdirAll(filepath.Dir(file), 0755)
+	}
+
+	name := "GameDatabase.db"
+	if backend == BackendJSON {
+		name = "GameDatabase.json"
+	}
+	legacy := filepath.Join(".", name)
+	dataDir, err := userDataDir()
+	if err != nil {
+		return legacy, fmt.Errorf("find the user data dir: %w", err)
+	}
+	file = filepath.Join(dataDir, appDirName, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return legacy, err
+	}
+	if backend != BackendMemory && !DbExists(file) && DbExists(legacy) {
+		if err := copyFile(legacy, file); err != nil {
+			return legacy, fmt.Errorf("copy %s to %s: %w", legacy, file, err)
+		}
+		fmt.Fprintf(os.Stderr, "copied the scores in %s to %s, the game uses that file from now on\n", legacy, file)
+	}
+	return file, nil
+}
+
+// copyFile copies to a temporary name first so a failed copy never looks like a real database
+func copyFile(from string, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(to + ".partial")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(to + ".partial")
+		return err
+	}
+	return os.Rename(to+".partial", to)
+}
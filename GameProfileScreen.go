@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/colornames"
+	"image/color"
+	"strings"
+	"time"
+)
+
+func openProfile(game *Game) {
+	name := game.infoBar.playerName
+	waitFor(game, "Loading your profile", func(store ScoreStore) func() {
+		profile, settings, err := ensureProfile(store, name)
+		var recent []Session
+		var bests PersonalBests
+		if err == nil {
+			err = retryBusy(func(ctx context.Context) error {
+				var err error
+				if recent, err = store.RecentSessions(ctx, profile.id, 10); err != nil {
+					return err
+				}
+				bests, err = store.PersonalBests(ctx, profile.id)
+				return err
+			})
+		}
+		return func() {
+			game.profile = profile
+			scoreProblem(game, err)
+			applySettings(game, settings)
+			game.recent, game.bests = recent, bests
+			game.profileOpen = true
+		}
+	})
+}
+
+func profileScreen(game *Game) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF1) || inpututil.IsKeyJustPressed(ebiten.KeyTab) ||
+		game.justPressed(ActionBack) || game.justPressed(ActionConfirm) {
+		game.profileOpen = false
+	}
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%02d", int(duration.Minutes()), int(duration.Seconds())%60)
+}
+
+func (game Game) drawProfile(screen *ebiten.Image) {
+	smallFont := makeFont(14, 72)
+	text.Draw(screen, "Profile: "+game.profile.name, makeFont(30, 72), 50, 260, colornames.Tomato)
+
+	bests := game.bests
+	text.Draw(screen, "Personal bests", makeFont(20, 72), 50, 300, colornames.Yellow)
+	summary := fmt.Sprintf("runs %d    wins %d    best score %d    highest level %d    longest run %s    fastest win %s",
+		bests.runs, bests.wins, bests.bestScore, bests.highestLevel, formatDuration(bests.longestRun), formatDuration(bests.fastestWin))
+	text.Draw(screen, summary, smallFont, 50, 325, color.White)
+	unlocks := "none yet"
+	if len(game.profile.unlocks) > 0 {
+		unlocks = strings.Join(game.profile.unlocks, ", ")
+	}
+	text.Draw(screen, "unlocks: "+unlocks, smallFont, 50, 345, color.White)
+
+	text.Draw(screen, "Recent runs", makeFont(20, 72), 50, 385, colornames.Yellow)
+	columns := []int{50, 230, 330, 400, 470, 560, 650}
+	headings := []string{"date", "score", "level", "lives", "result", "time", "difficulty"}
+	for i := range headings {
+		text.Draw(screen, headings[i], smallFont, columns[i], 410, colornames.Tomato)
+	}
+	if len(game.recent) == 0 {
+		text.Draw(screen, "no finished runs yet", smallFont, 50, 435, color.White)
+	}
+	for row, session := range game.recent {
+		values := []string{
+			session.startedAt.Local().Format("2006-01-02 15:04"),
+			fmt.Sprint(session.score),
+			fmt.Sprint(session.level),
+			fmt.Sprint(session.livesLeft),
+			session.outcome,
+			formatDuration(session.duration()),
+			session.difficulty,
+		}
+		for i := range values {
+			text.Draw(screen, values[i], smallFont, columns[i], 435+25*row, color.White)
+		}
+	}
+	text.Draw(screen, "F1, Tab, Enter or Backspace to go back", smallFont, 50, ScreenHeight-30, color.White)
+}
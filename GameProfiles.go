@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Profile is one person across all of their runs, names match without caring about case
+type Profile struct {
+	id      int
+	name    string
+	runs    int
+	best    int
+	unlocks []string
+}
+
+func (store *SQLiteStore) FindProfile(ctx context.Context, playername string) (Profile, bool, error) {
+	return findProfile(ctx, store.conn(), playername)
+}
+
+// findProfile looks the name up through conn, a transaction that is about to change the profile reads it this way
+func findProfile(ctx context.Context, conn sqlConn, playername string) (Profile, bool, error) {
+	var profile Profile
+	statement := "SELECT profiles.profile_id, profiles.name, COUNT(players.player_num), IFNULL(MAX(players.player_score), 0) " +
+		"FROM profiles LEFT JOIN players ON players.profile_id = profiles.profile_id " +
+		"WHERE profiles.name = ? GROUP BY profiles.profile_id"
+	err := conn.QueryRowContext(ctx, statement, strings.TrimSpace(playername)).Scan(&profile.id, &profile.name, &profile.runs, &profile.best)
+	if err == sql.ErrNoRows {
+		return Profile{}, false, nil
+	} else if err != nil {
+		return Profile{}, false, fmt.Errorf("find profile %q: %w", playername, err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT unlock FROM unlocks WHERE profile_id = ? ORDER BY unlocked_at", profile.id)
+	if err != nil {
+		return Profile{}, false, fmt.Errorf("find profile %q unlocks: %w", playername, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var unlock string
+		if err := rows.Scan(&unlock); err != nil {
+			return Profile{}, false, fmt.Errorf("find profile %q unlocks: %w", playername, err)
+		}
+		profile.unlocks = append(profile.unlocks, unlock)
+	}
+	return profile, true, rows.Err()
+}
+
+// EnsureProfile returns the profile for the name, making it the first time the name is seen
+func (store *SQLiteStore) EnsureProfile(ctx context.Context, playername string) (Profile, error) {
+	playername = strings.TrimSpace(playername)
+	statement := "INSERT INTO profiles (name) VALUES (?) " +
+		"ON CONFLICT(name) DO UPDATE SET last_seen = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')"
+	if _, err := store.conn().ExecContext(ctx, statement, playername); err != nil {
+		return Profile{}, fmt.Errorf("save profile %q: %w", playername, err)
+	}
+	profile, _, err := store.FindProfile(ctx, playername)
+	return profile, err
+}
+
+func (store *SQLiteStore) Unlock(ctx context.Context, profileID int, unlock string) error {
+	statement := "INSERT OR IGNORE INTO unlocks (profile_id, unlock) VALUES (?, ?)"
+	if _, err := store.conn().ExecContext(ctx, statement, profileID, unlock); err != nil {
+		return fmt.Errorf("unlock %q for profile %d: %w", unlock, profileID, err)
+	}
+	return nil
+}
+
+func (profile Profile) hasUnlock(unlock string) bool {
+	for _, got := range profile.unlocks {
+		if got == unlock {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/colornames"
+	"image/color"
+	"log"
+	"path/filepath"
+)
+
+// what the player can do about a damaged database
+const (
+	recoverRestore = iota
+	recoverFresh
+	recoverSkip
+	numRecoverChoices
+)
+
+// openScores opens the store and gets the high scores ready. A SQLite database that fails its
+// integrity check is not touched, the title screen asks the player what to do about it first.
+func openScores(game *Game, backend string) {
+	if game.writer != nil {
+		game.writer.Close()
+		for _, apply := range game.writer.Results() {
+			apply()
+		}
+	}
+	store, err := OpenStore(backend, game.dbPath)
+	if err != nil {
+		store = unavailableStore{err}
+	}
+	game.store = store
+	game.writer = NewScoreWriter(store)
+	if game.client != nil {
+		game.client.Close()
+		game.client = nil
+	}
+	if leaderboardURL != "" {
+		game.client = NewLeaderboardClient(leaderboardURL, store)
+	}
+	game.scoresErr = nil
+
+	ctx, cancel := dbContext()
+	defer cancel()
+	if sqlite, ok := store.(*SQLiteStore); ok && DbExists(game.dbPath) {
+		problems, err := sqlite.Check(ctx)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) > 0 {
+			log.Println("the database at", game.dbPath, "is damaged:", problems[0])
+			startRecovery(game, problems[0])
+			return
+		}
+	}
+	scoreProblem(game, store.Migrate(ctx))
+	game.board = newLeaderboardView(5)
+	scoreProblem(game, game.board.Refresh(ctx, store, 0))
+}
+
+func startRecovery(game *Game, problem string) {
+	game.recovering = true
+	game.recoveryProblem = problem
+	game.recoveryCursor = recoverRestore
+	game.backups, _ = listBackups(game.dbPath)
+	if len(game.backups) == 0 {
+		game.recoveryCursor = recoverFresh
+	}
+}
+
+func recoveryMenu(game *Game) {
+	if game.justPressed(ActionUp) && game.recoveryCursor > 0 {
+		game.recoveryCursor--
+	} else if game.justPressed(ActionDown) && game.recoveryCursor < numRecoverChoices-1 {
+		game.recoveryCursor++
+	}
+	if game.recoveryCursor == recoverRestore && len(game.backups) == 0 {
+		game.recoveryCursor = recoverFresh
+	}
+	if !game.justPressed(ActionConfirm) {
+		return
+	}
+
+	game.writer.Close()
+	game.store.Close()
+	game.writer = nil
+	ctx, cancel := dbContext()
+	defer cancel()
+	var err error
+	switch game.recoveryCursor {
+	case recoverRestore:
+		// the newest backup that is not damaged itself wins
+		err = errors.New("every backup is damaged too")
+		for i := len(game.backups) - 1; i >= 0 && err != nil; i-- {
+			err = RestoreDatabase(ctx, game.backups[i], game.dbPath)
+		}
+	case recoverFresh:
+		var aside string
+		if aside, err = setAside(game.dbPath, "damaged"); err == nil {
+			log.Println("the damaged database is kept at", aside)
+		}
+	case recoverSkip:
+		game.recovering = false
+		game.store = unavailableStore{errors.New("the database is damaged, " + game.recoveryProblem)}
+		game.writer = NewScoreWriter(game.store)
+		scoreProblem(game, game.store.Migrate(ctx))
+		return
+	}
+	game.recovering = false
+	openScores(game, BackendSQLite)
+	if err != nil {
+		log.Println("recovering the database:", err)
+		if game.recovering {
+			game.recoveryProblem = err.Error()
+		}
+	}
+}
+
+func (game Game) drawRecovery(screen *ebiten.Image) {
+	smallFont := makeFont(14, 72)
+	text.Draw(screen, "The score database is damaged", makeFont(30, 72), 50, 260, colornames.Tomato)
+	text.Draw(screen, game.dbPath, smallFont, 50, 290, color.White)
+	text.Draw(screen, "problem: "+game.recoveryProblem, smallFont, 50, 310, color.White)
+
+	choices := [numRecoverChoices]string{
+		"Restore the last good backup",
+		"Start fresh, the damaged file is kept next to it",
+		"Play without saving scores this time",
+	}
+	if len(game.backups) > 0 {
+		latest := game.backups[len(game.backups)-1]
+		choices[recoverRestore] = fmt.Sprintf("Restore the backup from %s (%s)", backupTime(latest, game.dbPath), filepath.Base(latest))
+	} else {
+		choices[recoverRestore] = "Restore a backup - there are none"
+	}
+	for i, choice := range choices {
+		rowColor := color.Color(color.White)
+		if i == game.recoveryCursor {
+			rowColor = colornames.Yellow
+		} else if i == recoverRestore && len(game.backups) == 0 {
+			rowColor = color.Gray{0x80}
+		}
+		text.Draw(screen, choice, makeFont(20, 72), 80, 360+40*i, rowColor)
+	}
+	text.Draw(screen, "Up / Down to choose, Enter to go ahead", smallFont, 50, ScreenHeight-30, color.White)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	replayFormat     = "pupperooo-replay-1"
+	coopReplayFormat = "pupperooo-replay-2" // adds how many dogs played, each step has a frame for every dog
+
+	// a replay may hold this many ticks more than the run lasted, the ticks spent in the pause menu
+	replayPauseTicks = 30 * 60 * 60
+)
+
+// Replay is a run as the seed it started from, the settings that change the rules and the input of every tick.
+// Played back through a World it ends with the same score, which is how a submitted score gets checked.
+type Replay struct {
+	seed         int64
+	difficulty   string
+	curve        AccelCurve
+	aimWithMouse bool
+	dogs         int
+	steps        []replayStep
+}
+
+// replayStep is the input of every dog held for ticks ticks in a row, most of a run is the same frames over and over
+type replayStep struct {
+	frames [maxDogs]InputFrame
+	ticks  int
+}
+
+func newReplay(world *World, seed int64) Replay {
+	return Replay{seed: seed, difficulty: world.difficulty.name, curve: world.moveConfig.curve, aimWithMouse: world.aimWithMouse,
+		dogs: world.numDogs}
+}
+
+// record adds the input of one play tick
+func (replay *Replay) record(frames [maxDogs]InputFrame) {
+	if !replay.aimWithMouse { // the world never looks at the aim without mouse aiming, leaving it out keeps the steps long
+		for i := range frames {
+			frames[i].aimX, frames[i].aimY = 0, 0
+		}
+	}
+	if last := len(replay.steps) - 1; last >= 0 && replay.steps[last].frames == frames {
+		replay.steps[last].ticks++
+		return
+	}
+	replay.steps = append(replay.steps, replayStep{frames: frames, ticks: 1})
+}
+
+// encode writes the replay as text, a header line and then one line per step. A one dog replay is written
+// the way it was before co-op, so it reads the same to an older copy of the game.
+func (replay Replay) encode() string {
+	var out strings.Builder
+	if replay.dogs > 1 {
+		fmt.Fprintf(&out, "%s %d %q %q %t %d\n", coopReplayFormat, replay.seed, replay.difficulty, curveNames[replay.curve],
+			replay.aimWithMouse, replay.dogs)
+	} else {
+		fmt.Fprintf(&out, "%s %d %q %q %t\n", replayFormat, replay.seed, replay.difficulty, curveNames[replay.curve], replay.aimWithMouse)
+	}
+	for _, step := range replay.steps {
+		for _, frame := range step.frames[:replay.dogs] {
+			fmt.Fprintf(&out, "%d %d %d ", frame.buttons, frame.aimX, frame.aimY)
+		}
+		fmt.Fprintf(&out, "%d\n", step.ticks)
+	}
+	return out.String()
+}
+
+func decodeReplay(encoded string) (Replay, error) {
+	var replay Replay
+	lines := bufio.NewScanner(strings.NewReader(encoded))
+	if !lines.Scan() {
+		return replay, fmt.Errorf("replay is empty")
+	}
+	var format, curve string
+	replay.dogs = 1
+	_, err := fmt.Sscanf(lines.Text(), "%s %d %q %q %t", &format, &replay.seed, &replay.difficulty, &curve, &replay.aimWithMouse)
+	if err == nil && format == coopReplayFormat {
+		_, err = fmt.Sscanf(lines.Text(), "%s %d %q %q %t %d", &format, &replay.seed, &replay.difficulty, &curve, &replay.aimWithMouse,
+			&replay.dogs)
+	} else if format != replayFormat {
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil || replay.dogs < 1 || replay.dogs > maxDogs {
+		return replay, fmt.Errorf("not a replay this version of the game can play")
+	}
+	replay.curve = -1
+	for i := range curveNames {
+		if curveNames[i] == curve {
+			replay.curve = AccelCurve(i)
+		}
+	}
+	if replay.curve < 0 {
+		return replay, fmt.Errorf("replay uses unknown movement %q", curve)
+	}
+	if difficultyNamed(replay.difficulty).name != replay.difficulty {
+		return replay, fmt.Errorf("replay uses unknown difficulty %q", replay.difficulty)
+	}
+	for line := 2; lines.Scan(); line++ {
+		step, err := decodeStep(lines.Text(), replay.dogs)
+		if err != nil {
+			return replay, fmt.Errorf("replay line %d: %q is not a step", line, lines.Text())
+		}
+		replay.steps = append(replay.steps, step)
+	}
+	return replay, lines.Err()
+}
+
+// decodeStep reads the buttons and aim of each dog and then how many ticks they were held
+func decodeStep(line string, dogs int) (replayStep, error) {
+	var step replayStep
+	fields := strings.Fields(line)
+	if len(fields) != 3*dogs+1 {
+		return step, fmt.Errorf("%d numbers", len(fields))
+	}
+	numbers := make([]int64, len(fields))
+	for i, field := range fields {
+		number, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return step, err
+		}
+		numbers[i] = number
+	}
+	for i := 0; i < dogs; i++ {
+		if numbers[3*i] < 0 || numbers[3*i] > math.MaxUint32 {
+			return step, fmt.Errorf("buttons %d", numbers[3*i])
+		}
+		step.frames[i] = InputFrame{buttons: uint32(numbers[3*i]), aimX: int(numbers[3*i+1]), aimY: int(numbers[3*i+2])}
+	}
+	step.ticks = int(numbers[3*dogs])
+	if step.ticks <= 0 {
+		return step, fmt.Errorf("%d ticks", step.ticks)
+	}
+	return step, nil
+}
+
+// ReplayResult is how a replayed run ended, in the same terms the game saves a session in
+type ReplayResult struct {
+	score     int
+	level     int
+	livesLeft int
+	ticks     int
+	outcome   string
+}
+
+// fits says whether the replay's steps add up to at most limit ticks
+func (replay Replay) fits(limit int) bool {
+	for _, step := range replay.steps {
+		if step.ticks > limit {
+			return false
+		}
+		limit -= step.ticks
+	}
+	return true
+}
+
+// simulate plays the replay back without drawing anything. It steps the world in the same order Game.Update does,
+// so the ticks and highest level come out the way the game counted them. It gives up once ctx is done.
+func (replay Replay) simulate(ctx context.Context, pictures worldPictures) (ReplayResult, error) {
+	world := World{
+		difficulty:   difficultyNamed(replay.difficulty),
+		moveConfig:   defaultMoveConfig(),
+		aimWithMouse: replay.aimWithMouse,
+		numDogs:      replay.dogs,
+	}
+	world.moveConfig.curve = replay.curve
+	pictures.apply(&world)
+	world.startRun(replay.seed)
+
+	result := ReplayResult{outcome: OutcomeQuit}
+	played := 0
+	for _, step := range replay.steps {
+		for i := 0; i < step.ticks; i++ {
+			if world.currentLevel == 0 || world.currentLevel == 4 {
+				break
+			}
+			if played++; played%1024 == 0 && ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			world.nextFrame(step.frames[:replay.dogs]...)
+			if paused, quit := world.pauseTick(); quit || paused {
+				continue // quitting from the pause menu is the last recorded tick anyway
+			}
+			result.ticks++
+			if world.currentLevel > result.level && world.currentLevel <= 3 {
+				result.level = world.currentLevel
+			}
+			if events := world.playTick(); events.died {
+				result.outcome = OutcomeLost
+			} else if world.currentLevel == 4 {
+				result.outcome = OutcomeWon
+			}
+		}
+	}
+	result.score = world.score
+	result.livesLeft = world.livesLeft()
+	return result, nil
+}
+
+// mismatch says how a run that was saved differs from its replay, "" when they agree
+func (result ReplayResult) mismatch(score int, session Session) string {
+	var differences []string
+	if result.score != score {
+		differences = append(differences, fmt.Sprintf("score %d, replay scores %d", score, result.score))
+	}
+	if result.level != session.level {
+		differences = append(differences, fmt.Sprintf("level %d, replay reaches %d", session.level, result.level))
+	}
+	if result.livesLeft != session.livesLeft {
+		differences = append(differences, fmt.Sprintf("%d lives left, replay has %d", session.livesLeft, result.livesLeft))
+	}
+	// the SQLite store keeps how long a run lasted and not its ticks, so the lengths are compared as it keeps them
+	if played := (Session{ticks: result.ticks}).duration(); played.Milliseconds() != session.duration().Milliseconds() {
+		differences = append(differences, fmt.Sprintf("lasted %s, replay lasts %s", session.duration(), played))
+	}
+	if result.outcome != session.outcome {
+		differences = append(differences, fmt.Sprintf("%s, replay %s", session.outcome, result.outcome))
+	}
+	return strings.Join(differences, "; ")
+}
+
+// checkReplay plays a submitted replay back and compares it with the score and session saved for the run.
+// A replay far longer than the run or one that cannot be played back here gets a reason too, so the run
+// waits for review instead of being taken on trust. The only error is ctx being done first.
+func checkReplay(ctx context.Context, replay Replay, score int, session Session) (string, error) {
+	if replay.seed != session.seed || replay.difficulty != session.difficulty || (replay.dogs > 1) != (session.mode == ModeCoop) {
+		return "replay is of a different run", nil
+	}
+	if !replay.fits(session.ticks + replayPauseTicks) {
+		return fmt.Sprintf("replay is far longer than the %s the run lasted", session.duration()), nil
+	}
+	pictures, err := loadWorldPictures()
+	if err != nil {
+		return "not checked, " + err.Error(), nil
+	}
+	result, err := replay.simulate(ctx, pictures)
+	if err != nil {
+		return "", err
+	}
+	return result.mismatch(score, session), nil
+}
+
+// worldPictures are the sizes of the game's images, which is all a simulated world needs of them
+type worldPictures struct {
+	player  Picture
+	frisbee Picture
+	khai    Picture
+	sophia  Picture
+	water   Picture
+}
+
+func (pictures worldPictures) apply(world *World) {
+	for i := 0; i < maxDogs; i++ {
+		world.dogs[i].pict, world.dogs[i].Weapon.pict = pictures.player, pictures.frisbee
+	}
+	for i := 0; i < numEnemies; i++ {
+		world.khaiSprite[i].pict, world.khaiSprite[i].Weapon.pict = pictures.khai, pictures.water
+		world.sophiaSprite[i].pict, world.sophiaSprite[i].Weapon.pict = pictures.sophia, pictures.water
+	}
+	setMaze(world, func(width int, height int) Picture { return pictureSize{width, height} })
+}
+
+var (
+	picturesLock sync.Mutex
+	worldPicts   *worldPictures
+)
+
+// loadWorldPictures reads the sizes from the image files without decoding the pixels. The sizes are kept once
+// read, a failure is not, so putting the images back is enough for the next replay to be checked.
+func loadWorldPictures() (worldPictures, error) {
+	picturesLock.Lock()
+	defer picturesLock.Unlock()
+	if worldPicts != nil {
+		return *worldPicts, nil
+	}
+	var err error
+	size := func(name string) Picture {
+		if err != nil {
+			return pictureSize{}
+		}
+		file, openErr := os.Open(filepath.Join("images", name))
+		if openErr != nil {
+			err = fmt.Errorf("replays need the game's images: %w", openErr)
+			return pictureSize{}
+		}
+		defer file.Close()
+		config, _, decodeErr := image.DecodeConfig(file)
+		if decodeErr != nil {
+			err = fmt.Errorf("replays need the game's images: %s: %w", name, decodeErr)
+		}
+		return pictureSize{config.Width, config.Height}
+	}
+	pictures := worldPictures{
+		player:  size("jackcharacter.png"),
+		frisbee: size("frisbee.png"),
+		khai:    size("dragonkhai.png"),
+		sophia:  size("ninjaphia.png"),
+		water:   size("watergun.png"),
+	}
+	if err != nil {
+		return pictures, err
+	}
+	worldPicts = &pictures
+	return pictures, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// playRun plays a run the way Game.Update does, with a made up player throwing at random and pausing now and then.
+// It returns the recording and the session the game would save for it.
+func playRun(t *testing.T, seed int64, maxTicks int) (Replay, Session) {
+	t.Helper()
+	pictures, err := loadWorldPictures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := World{difficulty: difficultyNamed("Normal"), moveConfig: defaultMoveConfig(), numDogs: 1}
+	pictures.apply(&world)
+	world.startRun(seed)
+	replay := newReplay(&world, seed)
+	session := Session{seed: seed, difficulty: world.difficulty.name, mode: ModeSolo, version: GameVersion,
+		startedAt: time.Now().Add(-time.Hour).Truncate(time.Second)}
+
+	// mostly stands still throwing frisbees, now and then walks a few steps, which keeps it alive a while
+	player := rand.New(rand.NewSource(seed))
+	moves := []Action{ActionUp, ActionDown, ActionLeft, ActionRight}
+	throws := []Action{ActionShootUp, ActionShootDown, ActionShootLeft, ActionShootRight}
+	var frame InputFrame
+	for tick := 0; tick < maxTicks && world.currentLevel != 4; tick++ {
+		switch tick % 20 {
+		case 0:
+			frame = hold(throws[player.Intn(len(throws))])
+			frame.aimX, frame.aimY = player.Intn(ScreenWidth), player.Intn(ScreenHeight)
+			if player.Intn(3) == 0 {
+				frame.Set(moves[player.Intn(len(moves))])
+			}
+		case 4:
+			frame = InputFrame{}
+		}
+		held := frame
+		if tick%500 == 100 || tick%500 == 130 { // into the pause menu and back out
+			held = hold(ActionPause)
+		}
+		world.nextFrame(held)
+		replay.record(world.frames())
+		if paused, _ := world.pauseTick(); paused {
+			continue
+		}
+		session.ticks++
+		if world.currentLevel > session.level && world.currentLevel <= 3 {
+			session.level = world.currentLevel
+		}
+		if events := world.playTick(); events.died {
+			session.outcome = OutcomeLost
+		}
+	}
+	if session.outcome == "" && world.currentLevel == 4 {
+		session.outcome = OutcomeWon
+	} else if session.outcome == "" {
+		session.outcome = OutcomeQuit
+	}
+	session.score, session.livesLeft = world.score, world.livesLeft()
+	session.endedAt = session.startedAt.Add(session.duration())
+	return replay, session
+}
+
+// saveRun saves a played run through the store the way the game and its score writer do
+func saveRun(t *testing.T, store ScoreStore, name string, replay Replay, session Session) Session {
+	t.Helper()
+	session = saveSession(t, store, name, session)
+	if mismatch, err := store.SubmitReplay(context.Background(), session.playerNum, replay); err != nil || mismatch != "" {
+		t.Fatalf("saving run %d: %q %v", session.playerNum, mismatch, err)
+	}
+	return session
+}
+
+// saveSession saves everything of a finished run but its replay
+func saveSession(t *testing.T, store ScoreStore, name string, session Session) Session {
+	t.Helper()
+	ctx := context.Background()
+	profile, err := store.EnsureProfile(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.profileID = profile.id
+	if session.playerNum, err = store.AddPlayer(ctx, profile); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StartSession(ctx, &session); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateScore(ctx, session.playerNum, session.score); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FinishSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestReplayPlaysBackTheRun(t *testing.T) {
+	pictures, err := loadWorldPictures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(1); seed <= 4; seed++ {
+		replay, session := playRun(t, seed, 3000)
+		decoded, err := decodeReplay(replay.encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := decoded.simulate(context.Background(), pictures)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ReplayResult{score: session.score, level: session.level, livesLeft: session.livesLeft, ticks: session.ticks,
+			outcome: session.outcome}
+		if result != want {
+			t.Fatalf("seed %d played back as %+v, the run was %+v", seed, result, want)
+		}
+		if mismatch, err := checkReplay(context.Background(), decoded, session.score, session); err != nil || mismatch != "" {
+			t.Fatalf("seed %d: %q %v", seed, mismatch, err)
+		}
+	}
+}
+
+func TestReplayLongerThanTheRun(t *testing.T) {
+	replay, session := playRun(t, 2, 600)
+	if session.outcome != OutcomeQuit {
+		t.Fatalf("the run is %s already, the pause after it would not be played", session.outcome)
+	}
+	// pausing for ever, checking it must not play every one of those ticks
+	replay.steps = append(replay.steps, replayStep{frames: [maxDogs]InputFrame{hold(ActionPause)}, ticks: math.MaxInt64 / 2})
+	mismatch, err := checkReplay(context.Background(), replay, session.score, session)
+	if err != nil || !strings.Contains(mismatch, "far longer") {
+		t.Fatalf("%q %v", mismatch, err)
+	}
+
+	// a pause that fits the allowance still stops when the caller gives up
+	replay.steps[len(replay.steps)-1].ticks = replayPauseTicks / 2
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := checkReplay(ctx, replay, session.score, session); err != context.Canceled {
+		t.Fatalf("checking after the caller gave up: %v", err)
+	}
+}
+
+func TestUncheckedRunWaitsForReview(t *testing.T) {
+	replay, session := playRun(t, 2, 600)
+	// no images here, and none read before
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	picturesLock.Lock()
+	read := worldPicts
+	worldPicts = nil
+	picturesLock.Unlock()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Chdir(dir)
+		picturesLock.Lock()
+		worldPicts = read
+		picturesLock.Unlock()
+	}()
+
+	for _, store := range []ScoreStore{migratedStore(t), NewMemoryStore()} {
+		saved := saveSession(t, store, "Huy", session)
+		mismatch, err := store.SubmitReplay(context.Background(), saved.playerNum, replay)
+		if err != nil || !strings.Contains(mismatch, "replays need the game's images") {
+			t.Fatalf("%T: %q %v", store, mismatch, err)
+		}
+		reviews, err := store.ReviewQueue(context.Background())
+		if err != nil || len(reviews) != 1 || reviews[0].playerNum != saved.playerNum || reviews[0].status != ReviewPending {
+			t.Fatalf("%T review queue %v %v", store, reviews, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// the statuses a run in the review queue can have, an accepted run goes back on the leaderboard
+const (
+	ReviewPending  = "pending"
+	ReviewAccepted = "accepted"
+	ReviewRejected = "rejected"
+)
+
+// Review is a run whose replay did not play out the way the run was saved,
+// it stays off the leaderboard until someone accepts it
+type Review struct {
+	playerNum int
+	name      string
+	score     int
+	reason    string
+	status    string
+}
+
+func (review Review) String() string {
+	return "run " + strconv.Itoa(review.playerNum) + " by " + review.name + " (score " + strconv.Itoa(review.score) + ") " +
+		review.status + ": " + review.reason
+}
+
+// reviewDecision turns the word typed after `scores review` into the status it gives the run
+func reviewDecision(decision string) (string, error) {
+	switch decision {
+	case "accept":
+		return ReviewAccepted, nil
+	case "reject":
+		return ReviewRejected, nil
+	}
+	return "", fmt.Errorf("%q is not a decision, use accept or reject", decision)
+}
+
+// SubmitReplay plays the run's replay back and compares it with the score and session saved for the run.
+// The replay is kept either way, a run that does not match or cannot be checked goes into the review queue
+// and the reason is returned.
+func (store *SQLiteStore) SubmitReplay(ctx context.Context, playerNum int, replay Replay) (string, error) {
+	var score int
+	var durationMs int64
+	session := Session{playerNum: playerNum}
+	statement := "SELECT players.player_score, IFNULL(sessions.highest_level, 0), IFNULL(sessions.lives_left, 0), " +
+		"IFNULL(sessions.outcome, ''), IFNULL(sessions.duration_ms, 0), IFNULL(sessions.difficulty, ''), IFNULL(sessions.seed, 0), " +
+		"IFNULL(sessions.mode, '') FROM players LEFT JOIN sessions ON sessions.player_num = players.player_num WHERE players.player_num = ?"
+	err := store.conn().QueryRowContext(ctx, statement, playerNum).Scan(&score, &session.level, &session.livesLeft,
+		&session.outcome, &durationMs, &session.difficulty, &session.seed, &session.mode)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("submit replay: no player number %d", playerNum)
+	} else if err != nil {
+		return "", fmt.Errorf("submit replay for run %d: %w", playerNum, err)
+	}
+	session.ticks = ticksIn(durationMs)
+	mismatch, err := checkReplay(ctx, replay, score, session)
+	if err != nil {
+		return "", fmt.Errorf("submit replay for run %d: %w", playerNum, err)
+	}
+	if err := store.SaveReplay(ctx, playerNum, replay, mismatch); err != nil {
+		return "", err
+	}
+	return mismatch, nil
+}
+
+// SaveReplay keeps the replay of a run that was checked already, with a reason the run goes into the review queue
+func (store *SQLiteStore) SaveReplay(ctx context.Context, playerNum int, replay Replay, reason string) error {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("save replay for run %d: %w", playerNum, err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO replays (player_num, replay) VALUES (?, ?)", playerNum, replay.encode()); err != nil {
+		return fmt.Errorf("save replay for run %d: %w", playerNum, err)
+	}
+	if reason == "" {
+		_, err = tx.ExecContext(ctx, "DELETE FROM review_queue WHERE player_num = ?", playerNum)
+	} else {
+		_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO review_queue (player_num, reason, status) VALUES (?, ?, ?)",
+			playerNum, reason, ReviewPending)
+	}
+	if err != nil {
+		return fmt.Errorf("save replay for run %d: %w", playerNum, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("save replay for run %d: %w", playerNum, err)
+	}
+	return nil
+}
+
+// ReviewQueue returns every run that did not match its replay, the ones still waiting first
+func (store *SQLiteStore) ReviewQueue(ctx context.Context) ([]Review, error) {
+	statement := "SELECT review_queue.player_num, players.player_name, players.player_score, review_queue.reason, review_queue.status " +
+		"FROM review_queue JOIN players ON players.player_num = review_queue.player_num " +
+		"ORDER BY review_queue.status != 'pending', review_queue.player_num"
+	rows, err := store.conn().QueryContext(ctx, statement)
+	if err != nil {
+		return nil, fmt.Errorf("review queue: %w", err)
+	}
+	defer rows.Close()
+	var reviews []Review
+	for rows.Next() {
+		var review Review
+		if err := rows.Scan(&review.playerNum, &review.name, &review.score, &review.reason, &review.status); err != nil {
+			return nil, fmt.Errorf("review queue: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+func (store *SQLiteStore) ReviewRun(ctx context.Context, playerNum int, status string) error {
+	result, err := store.conn().ExecContext(ctx, "UPDATE review_queue SET status = ? WHERE player_num = ?", status, playerNum)
+	if err != nil {
+		return fmt.Errorf("review run %d: %w", playerNum, err)
+	}
+	if changed, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("review run %d: %w", playerNum, err)
+	} else if changed == 0 {
+		return fmt.Errorf("run %d is not waiting for review", playerNum)
+	}
+	return nil
+}
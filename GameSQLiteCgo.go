@@ -0,0 +1,27 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"errors"
+	"github.com/mattn/go-sqlite3"
+)
+
+// the SQLite driver is C code, so it is only there when the game is built with cgo
+const sqliteAvailable = true
+
+// isBusy tells whether a write failed only because another connection had the database locked
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// isUnique tells whether a write failed because it would have made a UNIQUE column hold the same value twice
+func isUnique(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,15 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+// built without cgo there is no SQLite driver, the json and memory stores still work
+const sqliteAvailable = false
+
+func isBusy(err error) bool {
+	return false
+}
+
+func isUnique(err error) bool {
+	return false
+}
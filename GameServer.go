@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultServeAddr = ":8080"
+	maxSubmission    = 4 << 20 // a long run's replay is a few hundred kilobytes
+	maxNameLength    = 16      // the longest name the title screen lets a player type
+	maxKeyLength     = 100
+	recentRuns       = 10
+)
+
+// scoreSubmission is the body of POST /scores, a finished run with the replay that checks it
+type scoreSubmission struct {
+	RunRecord
+}
+
+// submissionJSON is the answer to POST /scores
+type submissionJSON struct {
+	Run    int    `json:"run"`
+	Rank   int    `json:"rank,omitempty"` // 0 while the run is waiting for review
+	Status string `json:"status"`         // accepted or pending
+	Reason string `json:"reason,omitempty"`
+}
+
+// playerJSON is the answer to GET /players/{name}
+type playerJSON struct {
+	Name         string      `json:"name"`
+	Runs         int         `json:"runs"`
+	BestScore    int         `json:"best_score"`
+	Wins         int         `json:"wins"`
+	HighestLevel int         `json:"highest_level"`
+	LongestRunMs int64       `json:"longest_run_ms"`
+	FastestWinMs int64       `json:"fastest_win_ms,omitempty"`
+	Unlocks      []string    `json:"unlocks"`
+	Recent       []RunRecord `json:"recent"`
+}
+
+// ScoreServer shares one score store over HTTP so several copies of the game on a network can use one leaderboard
+type ScoreServer struct {
+	store      ScoreStore
+	mux        *http.ServeMux
+	submitting sync.Mutex // a retry can arrive while the first try of the same key is still being saved
+}
+
+func NewScoreServer(store ScoreStore) *ScoreServer {
+	server := &ScoreServer{store: store, mux: http.NewServeMux()}
+	server.mux.HandleFunc("/scores", server.scores)
+	server.mux.HandleFunc("/players/", server.player)
+	return server
+}
+
+func (server *ScoreServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+// serveScores runs the score server until ctrl-c or a kill signal
+func serveScores(store ScoreStore, out io.Writer, addr string) error {
+	httpServer := &http.Server{Addr: addr, Handler: NewScoreServer(store), ReadTimeout: 10 * time.Second, WriteTimeout: 10 * time.Second}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- httpServer.ListenAndServe()
+	}()
+	fmt.Fprintln(out, "serving scores on", addr)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	select {
+	case err := <-failed:
+		return fmt.Errorf("serve: %w", err)
+	case sig := <-stop:
+		fmt.Fprintln(out, "got", sig, "- shutting down")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(ctx)
+}
+
+func (server *ScoreServer) scores(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		server.listScores(w, r)
+	case http.MethodPost:
+		server.submitScore(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "use GET or POST")
+	}
+}
+
+// listScores answers GET /scores?top=N&offset=N&window=all|day|week&difficulty=&mode=
+func (server *ScoreServer) listScores(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := LeaderboardQuery{now: time.Now(), limit: 10, difficulty: params.Get("difficulty"), mode: params.Get("mode")}
+	var err error
+	if query.limit, err = intParam(params, "top", query.limit); err != nil || query.limit < 1 || query.limit > 100 {
+		writeError(w, http.StatusBadRequest, "top must be a number from 1 to 100")
+		return
+	}
+	if query.offset, err = intParam(params, "offset", 0); err != nil || query.offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must be a number of 0 or more")
+		return
+	}
+	switch params.Get("window") {
+	case "", "all":
+		query.window = WindowAllTime
+	case "day", "today":
+		query.window = WindowDaily
+	case "week":
+		query.window = WindowWeekly
+	default:
+		writeError(w, http.StatusBadRequest, "window must be all, day or week")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	entries, total, err := server.store.Leaderboard(ctx, query)
+	if err != nil {
+		server.storeError(w, err)
+		return
+	}
+	scores := []scoreJSON{}
+	for _, entry := range entries {
+		scores = append(scores, toScoreJSON(entry))
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Total  int         `json:"total"`
+		Scores []scoreJSON `json:"scores"`
+	}{total, scores})
+}
+
+// submitScore answers POST /scores, the run is checked against its replay and then saved in one transaction.
+// A run that does not match is saved too but waits for review, the answer is 202 instead of 201.
+// With an Idempotency-Key header a run is only saved once, trying the same key again gets the first answer back.
+// The answer is saved with the run, so a retry after a failed save finds neither and saves the run once.
+func (server *ScoreServer) submitScore(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxKeyLength {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxKeyLength))
+		return
+	}
+	var submission scoreSubmission
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmission))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&submission); err != nil {
+		writeError(w, http.StatusBadRequest, "bad score: "+err.Error())
+		return
+	}
+	session, err := submission.session()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	replay, err := decodeReplay(submission.Replay)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad replay: "+err.Error())
+		return
+	}
+
+	// a replay runs the whole game again, so this gets longer than a read
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if key != "" {
+		server.submitting.Lock()
+		defer server.submitting.Unlock()
+		saved, found, err := server.store.SubmissionAnswer(ctx, key)
+		if err != nil {
+			server.storeError(w, err)
+			return
+		}
+		var answer submissionJSON
+		if found && json.Unmarshal([]byte(saved), &answer) == nil {
+			writeAnswer(w, answer)
+			return
+		}
+	}
+	mismatch, err := checkReplay(ctx, replay, session.score, session)
+	if err != nil {
+		server.storeError(w, err)
+		return
+	}
+
+	var answer submissionJSON
+	err = inOneTransaction(ctx, server.store, func(ctx context.Context, store ScoreStore) error {
+		var err error
+		if answer, err = saveSubmission(ctx, store, submission.Player, session, replay, mismatch); err != nil || key == "" {
+			return err
+		}
+		saved, _ := json.Marshal(answer)
+		return store.SaveSubmissionAnswer(ctx, key, string(saved))
+	})
+	if err != nil {
+		server.storeError(w, err)
+		return
+	}
+	if mismatch != "" {
+		log.Printf("run %d by %s does not match its replay (%s), it is waiting for review", answer.Run, submission.Player, mismatch)
+	}
+	writeAnswer(w, answer)
+}
+
+// saveSubmission saves a run that was checked against its replay and works out the answer to it
+func saveSubmission(ctx context.Context, store ScoreStore, name string, session Session, replay Replay, mismatch string) (submissionJSON, error) {
+	profile, err := store.EnsureProfile(ctx, name)
+	if err != nil {
+		return submissionJSON{}, err
+	}
+	session.profileID = profile.id
+	if session.playerNum, err = store.AddPlayer(ctx, profile); err != nil {
+		return submissionJSON{}, err
+	}
+	if err := store.StartSession(ctx, &session); err != nil {
+		return submissionJSON{}, err
+	}
+	if err := store.UpdateScore(ctx, session.playerNum, session.score); err != nil {
+		return submissionJSON{}, err
+	}
+	if err := store.FinishSession(ctx, session); err != nil {
+		return submissionJSON{}, err
+	}
+	if err := store.SaveReplay(ctx, session.playerNum, replay, mismatch); err != nil {
+		return submissionJSON{}, err
+	}
+
+	answer := submissionJSON{Run: session.playerNum, Status: ReviewAccepted}
+	if mismatch != "" {
+		answer.Status, answer.Reason = ReviewPending, mismatch
+		return answer, nil
+	}
+	entry, found, err := store.LeaderboardRank(ctx, LeaderboardQuery{window: WindowAllTime, now: time.Now()}, session.playerNum)
+	if found {
+		answer.Rank = entry.rank
+	}
+	return answer, err
+}
+
+// inOneTransaction runs write as a batch of one when the store has batches, so either all of it is saved or none of it
+func inOneTransaction(ctx context.Context, store ScoreStore, write func(ctx context.Context, store ScoreStore) error) error {
+	batcher, ok := store.(Batcher)
+	if !ok {
+		return write(ctx, store)
+	}
+	errs, err := batcher.Batch(ctx, []func(ctx context.Context, store ScoreStore) error{write})
+	if err != nil {
+		return err
+	}
+	return errs[0]
+}
+
+// writeAnswer answers a submission, 202 instead of 201 when the run is waiting for review
+func writeAnswer(w http.ResponseWriter, answer submissionJSON) {
+	if answer.Status == ReviewPending {
+		writeJSON(w, http.StatusAccepted, answer)
+		return
+	}
+	writeJSON(w, http.StatusCreated, answer)
+}
+
+// sessionRecord is a finished session the way runs are exported and posted
+func sessionRecord(player string, session Session) RunRecord {
+	return RunRecord{Player: player, Score: session.score,
+		StartedAt: session.startedAt.UTC().Format(time.RFC3339), EndedAt: session.endedAt.UTC().Format(time.RFC3339),
+		DurationMs: session.duration().Milliseconds(), Level: session.level, LivesLeft: session.livesLeft,
+		Outcome: session.outcome, Difficulty: session.difficulty, Mode: session.mode, Seed: session.seed, GameVersion: session.version}
+}
+
+// session checks a submission and turns it into the session the store saves
+func (submission scoreSubmission) session() (Session, error) {
+	submission.Player = strings.TrimSpace(submission.Player)
+	if submission.Player == "" || len(submission.Player) > maxNameLength {
+		return Session{}, fmt.Errorf("player must be 1 to %d characters", maxNameLength)
+	}
+	if submission.Replay == "" {
+		return Session{}, errors.New("a score needs its replay")
+	}
+	if submission.Outcome != OutcomeWon && submission.Outcome != OutcomeLost && submission.Outcome != OutcomeQuit {
+		return Session{}, fmt.Errorf("outcome must be %s, %s or %s", OutcomeWon, OutcomeLost, OutcomeQuit)
+	}
+	if !knownMode(submission.Mode) {
+		return Session{}, fmt.Errorf("unknown mode %q", submission.Mode)
+	}
+	if difficultyNamed(submission.Difficulty).name != submission.Difficulty {
+		return Session{}, fmt.Errorf("unknown difficulty %q", submission.Difficulty)
+	}
+	startedAt, err := time.Parse(time.RFC3339, submission.StartedAt)
+	if err != nil {
+		return Session{}, errors.New("started_at must be an RFC 3339 time")
+	}
+	endedAt := time.Now()
+	if submission.EndedAt != "" {
+		if endedAt, err = time.Parse(time.RFC3339, submission.EndedAt); err != nil {
+			return Session{}, errors.New("ended_at must be an RFC 3339 time")
+		}
+	}
+	return Session{
+		startedAt: startedAt, endedAt: endedAt, ticks: ticksIn(submission.DurationMs), score: submission.Score,
+		level: submission.Level, livesLeft: submission.LivesLeft, outcome: submission.Outcome,
+		difficulty: submission.Difficulty, mode: submission.Mode, seed: submission.Seed, version: submission.GameVersion,
+	}, nil
+}
+
+// player answers GET /players/{name} with the player's bests, unlocks and latest runs
+func (server *ScoreServer) player(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	name, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/players/"))
+	if err != nil || strings.TrimSpace(name) == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "no such player")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	profile, found, err := server.store.FindProfile(ctx, name)
+	if err != nil {
+		server.storeError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "no such player")
+		return
+	}
+	bests, err := server.store.PersonalBests(ctx, profile.id)
+	if err != nil {
+		server.storeError(w, err)
+		return
+	}
+	sessions, err := server.store.RecentSessions(ctx, profile.id, recentRuns)
+	if err != nil {
+		server.storeError(w, err)
+		return
+	}
+
+	player := playerJSON{Name: profile.name, Runs: profile.runs, BestScore: profile.best, Wins: bests.wins,
+		HighestLevel: bests.highestLevel, LongestRunMs: bests.longestRun.Milliseconds(), FastestWinMs: bests.fastestWin.Milliseconds(),
+		Unlocks: append([]string{}, profile.unlocks...), Recent: []RunRecord{}}
+	for _, session := range sessions {
+		player.Recent = append(player.Recent, sessionRecord(profile.name, session))
+	}
+	writeJSON(w, http.StatusOK, player)
+}
+
+// storeError answers 503 when the store fails, the details only go to the server's log
+func (server *ScoreServer) storeError(w http.ResponseWriter, err error) {
+	log.Println("scores unavailable:", err)
+	writeError(w, http.StatusServiceUnavailable, "scores unavailable")
+}
+
+func intParam(params url.Values, name string, fallback int) (int, error) {
+	if params.Get(name) == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(params.Get(name))
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Println("writing answer:", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{message})
+}
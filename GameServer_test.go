@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testServer serves a new SQLite store over HTTP
+func testServer(t *testing.T) (*httptest.Server, *SQLiteStore) {
+	t.Helper()
+	store := migratedStore(t)
+	server := httptest.NewServer(NewScoreServer(store))
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+// submission is a played run as the game posts it, the score moved by cheat
+func submission(t *testing.T, seed int64, cheat int) RunRecord {
+	t.Helper()
+	replay, session := playRun(t, seed, 3000)
+	session.score += cheat
+	record := sessionRecord("Huy", session)
+	record.Replay = replay.encode()
+	return record
+}
+
+// call sends one request and decodes the answer into answer when it is not nil
+func call(t *testing.T, method string, url string, key string, body io.Reader, answer interface{}) int {
+	t.Helper()
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		request.Header.Set("Idempotency-Key", key)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if answer != nil {
+		if err := json.NewDecoder(response.Body).Decode(answer); err != nil {
+			t.Fatalf("%s %s answered %d: %v", method, url, response.StatusCode, err)
+		}
+	}
+	return response.StatusCode
+}
+
+func post(t *testing.T, url string, key string, record RunRecord, answer interface{}) int {
+	t.Helper()
+	return call(t, http.MethodPost, url+"/scores", key, bytes.NewReader(mustJSON(t, record)), answer)
+}
+
+func TestSubmitScore(t *testing.T) {
+	server, store := testServer(t)
+	good, cheated := submission(t, 2, 0), submission(t, 3, 900)
+
+	var answer submissionJSON
+	if status := post(t, server.URL, "", good, &answer); status != http.StatusCreated ||
+		answer.Status != ReviewAccepted || answer.Rank != 1 {
+		t.Fatalf("good run answered %d %+v", status, answer)
+	}
+	answer = submissionJSON{}
+	if status := post(t, server.URL, "", cheated, &answer); status != http.StatusAccepted ||
+		answer.Status != ReviewPending || !strings.Contains(answer.Reason, "replay scores") || answer.Rank != 0 {
+		t.Fatalf("cheated run answered %d %+v", status, answer)
+	}
+	if got := dump(t, store.db, "SELECT player_num, status FROM review_queue"); got != "2|pending" {
+		t.Fatalf("review queue %q", got)
+	}
+	if got := dump(t, store.db, "SELECT COUNT(*) FROM replays"); got != "2" {
+		t.Fatalf("%s replays saved", got)
+	}
+
+	var board struct {
+		Total  int         `json:"total"`
+		Scores []scoreJSON `json:"scores"`
+	}
+	if status := call(t, http.MethodGet, server.URL+"/scores?top=5", "", nil, &board); status != http.StatusOK ||
+		board.Total != 1 || len(board.Scores) != 1 || board.Scores[0].Score != good.Score || board.Scores[0].Player != "Huy" {
+		t.Fatalf("board answered %d %+v, the run waiting for review is not on it", status, board)
+	}
+	if status := call(t, http.MethodGet, server.URL+"/scores?window=year", "", nil, nil); status != http.StatusBadRequest {
+		t.Fatalf("unknown window answered %d", status)
+	}
+
+	var player playerJSON
+	if status := call(t, http.MethodGet, server.URL+"/players/huy", "", nil, &player); status != http.StatusOK ||
+		player.Name != "Huy" || player.Runs != 2 || len(player.Recent) != 2 {
+		t.Fatalf("player answered %d %+v", status, player)
+	}
+	if status := call(t, http.MethodGet, server.URL+"/players/nobody", "", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("unknown player answered %d", status)
+	}
+}
+
+func TestSubmitScoreRejectsBadBodies(t *testing.T) {
+	server, store := testServer(t)
+	noReplay := submission(t, 2, 0)
+	noReplay.Replay = ""
+	badReplay := submission(t, 2, 0)
+	badReplay.Replay = "not a replay"
+	huge := `{"player": "Huy", "replay": "` + strings.Repeat("0", maxSubmission) + `"}`
+	for name, body := range map[string]string{
+		"not json":       "{",
+		"unknown fields": `{"player": "Huy", "cheat": true}`,
+		"no replay":      string(mustJSON(t, noReplay)),
+		"bad replay":     string(mustJSON(t, badReplay)),
+		"too big":        huge,
+	} {
+		var answer struct {
+			Error string `json:"error"`
+		}
+		if status := call(t, http.MethodPost, server.URL+"/scores", "", strings.NewReader(body), &answer); status != http.StatusBadRequest ||
+			answer.Error == "" {
+			t.Fatalf("%s answered %d %+v", name, status, answer)
+		}
+	}
+	if got := dump(t, store.db, "SELECT COUNT(*) FROM players"); got != "0" {
+		t.Fatalf("bad bodies saved %s runs", got)
+	}
+}
+
+func TestSubmitScoreOnceForAKey(t *testing.T) {
+	server, store := testServer(t)
+	run := submission(t, 2, 0)
+
+	// saving the answer fails, so the run that was saved with it is taken back
+	if _, err := store.db.Exec("CREATE TRIGGER fail_answers BEFORE INSERT ON received_submissions " +
+		"BEGIN SELECT RAISE(ABORT, 'disk full'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if status := post(t, server.URL, "run-1", run, nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("failed save answered %d", status)
+	}
+	if got := dump(t, store.db, "SELECT COUNT(*) FROM players"); got != "0" {
+		t.Fatalf("a failed save left %s runs", got)
+	}
+	if _, err := store.db.Exec("DROP TRIGGER fail_answers"); err != nil {
+		t.Fatal(err)
+	}
+
+	var first, again submissionJSON
+	if status := post(t, server.URL, "run-1", run, &first); status != http.StatusCreated {
+		t.Fatalf("retry answered %d", status)
+	}
+	if status := post(t, server.URL, "run-1", run, &again); status != http.StatusCreated || again != first {
+		t.Fatalf("the same key again answered %d %+v, first %+v", status, again, first)
+	}
+	if got := dump(t, store.db, "SELECT COUNT(*) FROM players"); got != "1" {
+		t.Fatalf("%s runs saved for one key", got)
+	}
+	if status := post(t, server.URL, "run-2", run, nil); status != http.StatusCreated {
+		t.Fatalf("a new key answered %d", status)
+	}
+	if got := dump(t, store.db, "SELECT COUNT(*) FROM players"); got != "2" {
+		t.Fatalf("%s runs saved for two keys", got)
+	}
+}
+
+func TestSubmitScoreToTheMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	server := httptest.NewServer(NewScoreServer(store))
+	defer server.Close()
+	var answer submissionJSON
+	if status := post(t, server.URL, "run-1", submission(t, 3, 900), &answer); status != http.StatusAccepted {
+		t.Fatalf("cheated run answered %d %+v", status, answer)
+	}
+	reviews, err := store.ReviewQueue(context.Background())
+	if err != nil || len(reviews) != 1 || reviews[0].playerNum != answer.Run {
+		t.Fatalf("review queue %v %v", reviews, err)
+	}
+	saved, found, _ := store.SubmissionAnswer(context.Background(), "run-1")
+	if !found || !strings.Contains(saved, `"pending"`) {
+		t.Fatalf("answer saved for the key %q %t", saved, found)
+	}
+}
+
+func mustJSON(t *testing.T, value interface{}) []byte {
+	t.Helper()
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Session is everything worth keeping about one run of the game
+type Session struct {
+	id         int
+	profileID  int
+	playerNum  int
+	startedAt  time.Time
+	endedAt    time.Time
+	ticks      int // ticks spent playing, pauses not included
+	score      int
+	level      int // highest level reached
+	livesLeft  int
+	outcome    string
+	difficulty string
+	mode       string
+	seed       int64
+	version    string
+}
+
+const (
+	ModeSolo   = "solo"
+	ModeArcade = "arcade" // played without a name, kept only when it makes the high scores
+	ModeCoop   = "coop"   // two dogs on one machine, the run is saved under the team's name with the team's score
+
+	OutcomeWon  = "won"
+	OutcomeLost = "lost"
+	OutcomeQuit = "quit"
+)
+
+var modeNames = []string{ModeSolo, ModeArcade, ModeCoop}
+
+func (session Session) duration() time.Duration {
+	return time.Duration(session.ticks) * time.Second / 60
+}
+
+// ticksIn turns a saved duration back into ticks, the milliseconds were rounded down so they are rounded back up
+func ticksIn(durationMs int64) int {
+	return int((durationMs*60 + 999) / 1000)
+}
+
+func knownMode(mode string) bool {
+	for _, known := range modeNames {
+		if mode == known {
+			return true
+		}
+	}
+	return false
+}
+
+// PersonalBests sums up all the finished sessions of one profile
+type PersonalBests struct {
+	runs         int
+	wins         int
+	bestScore    int
+	highestLevel int
+	longestRun   time.Duration
+	fastestWin   time.Duration
+}
+
+// Difficulty changes how often the toddlers move toward the dog and throw water
+type Difficulty struct {
+	name       string
+	moveEvery  int
+	shootEvery int
+}
+
+var difficulties = []Difficulty{
+	{"Easy", 300, 150},
+	{"Normal", 200, 100},
+	{"Hard", 120, 60},
+}
+
+func difficultyNamed(name string) Difficulty {
+	for _, difficulty := range difficulties {
+		if difficulty.name == name {
+			return difficulty
+		}
+	}
+	return difficulties[1]
+}
+
+// StartSession records a new run, the run's seed and game version are part of its score's signature
+func (store *SQLiteStore) StartSession(ctx context.Context, session *Session) error {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	defer tx.Rollback()
+	matched, err := store.matches(ctx, tx, session.playerNum)
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	statement := "INSERT INTO sessions (profile_id, player_num, started_at, difficulty, mode, seed, game_version) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.ExecContext(ctx, statement, session.profileID, session.playerNum,
+		session.startedAt.UTC().Format(time.RFC3339), session.difficulty, session.mode, session.seed, session.version)
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	if err := store.sign(ctx, tx, session.playerNum, matched); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	session.id = int(id)
+	return nil
+}
+
+// updateSession runs an update of the session's row and signs its run again since the duration may have changed
+func (store *SQLiteStore) updateSession(ctx context.Context, session Session, statement string, args ...interface{}) error {
+	tx, err := store.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	matched, err := store.matches(ctx, tx, session.playerNum)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, statement, args...); err != nil {
+		return err
+	}
+	if err := store.sign(ctx, tx, session.playerNum, matched); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (store *SQLiteStore) FinishSession(ctx context.Context, session Session) error {
+	statement := "UPDATE sessions SET ended_at = ?, duration_ms = ?, final_score = ?, highest_level = ?, lives_left = ?, outcome = ? " +
+		"WHERE session_id = ?"
+	err := store.updateSession(ctx, session, statement, session.endedAt.UTC().Format(time.RFC3339), session.duration().Milliseconds(),
+		session.score, session.level, session.livesLeft, session.outcome, session.id)
+	if err != nil {
+		return fmt.Errorf("finish session %d: %w", session.id, err)
+	}
+	return nil
+}
+
+// CheckpointSession saves how far a run has got while it is still being played, a finished session is left alone
+func (store *SQLiteStore) CheckpointSession(ctx context.Context, session Session) error {
+	statement := "UPDATE sessions SET duration_ms = ?, final_score = ?, highest_level = ?, lives_left = ? " +
+		"WHERE session_id = ? AND outcome IS NULL"
+	err := store.updateSession(ctx, session, statement, session.duration().Milliseconds(), session.score, session.level,
+		session.livesLeft, session.id)
+	if err != nil {
+		return fmt.Errorf("checkpoint session %d: %w", session.id, err)
+	}
+	return nil
+}
+
+func (store *SQLiteStore) RecentSessions(ctx context.Context, profileID int, limit int) ([]Session, error) {
+	var sessions []Session
+	statement := "SELECT session_id, player_num, started_at, ended_at, duration_ms, final_score, highest_level, lives_left, outcome, difficulty, mode, seed, game_version " +
+		"FROM sessions WHERE profile_id = ? AND outcome IS NOT NULL ORDER BY started_at DESC, session_id DESC LIMIT ?"
+	rows, err := store.conn().QueryContext(ctx, statement, profileID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("recent sessions for profile %d: %w", profileID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		session := Session{profileID: profileID}
+		var startedAt, endedAt string
+		var durationMs int64
+		err := rows.Scan(&session.id, &session.playerNum, &startedAt, &endedAt, &durationMs, &session.score, &session.level,
+			&session.livesLeft, &session.outcome, &session.difficulty, &session.mode, &session.seed, &session.version)
+		if err != nil {
+			return nil, fmt.Errorf("recent sessions for profile %d: %w", profileID, err)
+		}
+		session.startedAt, _ = time.Parse(time.RFC3339, startedAt)
+		session.endedAt, _ = time.Parse(time.RFC3339, endedAt)
+		session.ticks = ticksIn(durationMs)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (store *SQLiteStore) PersonalBests(ctx context.Context, profileID int) (PersonalBests, error) {
+	var bests PersonalBests
+	var longestMs, fastestMs int64
+	statement := "SELECT COUNT(*), IFNULL(SUM(outcome = 'won'), 0), IFNULL(MAX(final_score), 0), IFNULL(MAX(highest_level), 0), " +
+		"IFNULL(MAX(duration_ms), 0), IFNULL(MIN(CASE WHEN outcome = 'won' THEN duration_ms END), 0) " +
+		"FROM sessions WHERE profile_id = ? AND outcome IS NOT NULL"
+	err := store.conn().QueryRowContext(ctx, statement, profileID).Scan(&bests.runs, &bests.wins, &bests.bestScore,
+		&bests.highestLevel, &longestMs, &fastestMs)
+	if err != nil {
+		return PersonalBests{}, fmt.Errorf("personal bests for profile %d: %w", profileID, err)
+	}
+	bests.longestRun = time.Duration(longestMs) * time.Millisecond
+	bests.fastestWin = time.Duration(fastestMs) * time.Millisecond
+	return bests, nil
+}
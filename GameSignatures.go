@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	keyEnvVar   = "PUPPEROOO_SCORE_KEY"
+	keyFileName = "ScoreKey.txt"
+	keyTries    = 20 // reads of an empty key file before giving up on it
+	keyWait     = 50 * time.Millisecond
+
+	signedSchema = 6 // the migration that added players.signature
+)
+
+// ScoreKey signs every score record so a score edited outside the game no longer matches its signature
+type ScoreKey []byte
+
+// ScoreRecord is everything a score's signature covers
+type ScoreRecord struct {
+	player   string
+	score    int
+	seed     int64
+	duration int64 // milliseconds
+	version  string
+}
+
+// loadScoreKey reads the key from $PUPPEROOO_SCORE_KEY, or from ScoreKey.txt next to the store's file,
+// making a random one there the first time. Every copy of the game sharing a database has to use the same key.
+func loadScoreKey(dbfile string) (ScoreKey, error) {
+	if key := os.Getenv(keyEnvVar); key != "" {
+		return ScoreKey(key), nil
+	}
+	file := filepath.Join(filepath.Dir(dbfile), keyFileName)
+	for tries := 1; ; tries++ {
+		contents, err := ioutil.ReadFile(file)
+		key := strings.TrimSpace(string(contents))
+		switch {
+		case err == nil && key != "":
+			return ScoreKey(key), nil
+		case err == nil && tries < keyTries:
+			time.Sleep(keyWait) // an older copy of the game may still be writing it
+		case err == nil:
+			return nil, fmt.Errorf("score key %s is empty", file)
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("read score key: %w", err)
+		default:
+			made, err := makeScoreKey(file)
+			if err != nil || made != nil {
+				return made, err
+			}
+			// another copy of the game made the key first, theirs is read on the next try
+		}
+	}
+}
+
+// makeScoreKey writes a new random key to file. The key is written to a file of its own first and then linked
+// into place, so the key file never exists without the key in it. It returns nil when file is already there.
+func makeScoreKey(file string) (ScoreKey, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("make score key: %w", err)
+	}
+	key := hex.EncodeToString(random)
+	out, err := ioutil.TempFile(filepath.Dir(file), keyFileName+".*")
+	if err != nil {
+		return nil, fmt.Errorf("make score key: %w", err)
+	}
+	defer os.Remove(out.Name())
+	_, err = out.WriteString(key + "\n")
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Link(out.Name(), file)
+	}
+	if os.IsExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("make score key: %w", err)
+	}
+	return ScoreKey(key), nil
+}
+
+// randomScoreKey is for stores that forget everything when the game closes
+func randomScoreKey() ScoreKey {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+func (key ScoreKey) sign(record ScoreRecord) string {
+	mac := hmac.New(sha256.New, key)
+	// quoting the strings keeps a name with spaces in it from lining up with a different record
+	fmt.Fprintf(mac, "%q %d %d %d %q", record.player, record.score, record.seed, record.duration, record.version)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (key ScoreKey) verify(record ScoreRecord, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	actual, _ := hex.DecodeString(key.sign(record))
+	return hmac.Equal(expected, actual)
+}
+
+// signedRecordSQL reads the signed fields of runs, a run without a session signs zeros in their place
+const signedRecordSQL = "SELECT players.player_num, players.player_name, players.player_score, IFNULL(sessions.seed, 0), " +
+	"IFNULL(sessions.duration_ms, 0), IFNULL(sessions.game_version, ''), IFNULL(players.signature, '') " +
+	"FROM players LEFT JOIN sessions ON sessions.player_num = players.player_num"
+
+type signedRow struct {
+	playerNum int
+	record    ScoreRecord
+	signature string
+}
+
+func scanSignedRows(rows *sql.Rows) ([]signedRow, error) {
+	defer rows.Close()
+	var signed []signedRow
+	for rows.Next() {
+		var row signedRow
+		err := rows.Scan(&row.playerNum, &row.record.player, &row.record.score, &row.record.seed,
+			&row.record.duration, &row.record.version, &row.signature)
+		if err != nil {
+			return nil, err
+		}
+		signed = append(signed, row)
+	}
+	return signed, rows.Err()
+}
+
+// matches tells whether a run still matches its signature, it is asked before a write that changes the run
+func (store *SQLiteStore) matches(ctx context.Context, tx sqlConn, playerNum int) (bool, error) {
+	rows, err := tx.QueryContext(ctx, signedRecordSQL+" WHERE players.player_num = ?", playerNum)
+	if err != nil {
+		return false, fmt.Errorf("check run %d: %w", playerNum, err)
+	}
+	signed, err := scanSignedRows(rows)
+	if err != nil {
+		return false, fmt.Errorf("check run %d: %w", playerNum, err)
+	}
+	for _, row := range signed {
+		if !store.key.verify(row.record, row.signature) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sign signs a run again after a write inside tx changed one of its signed fields. A run that did not match
+// its signature before the write stays unsigned, otherwise the game writing over an edited score would bless it.
+func (store *SQLiteStore) sign(ctx context.Context, tx sqlConn, playerNum int, matched bool) error {
+	if !matched {
+		return nil
+	}
+	rows, err := tx.QueryContext(ctx, signedRecordSQL+" WHERE players.player_num = ?", playerNum)
+	if err != nil {
+		return fmt.Errorf("sign run %d: %w", playerNum, err)
+	}
+	signed, err := scanSignedRows(rows)
+	if err != nil {
+		return fmt.Errorf("sign run %d: %w", playerNum, err)
+	}
+	for _, row := range signed {
+		if _, err := tx.ExecContext(ctx, "UPDATE players SET signature = ? WHERE player_num = ?", store.key.sign(row.record), playerNum); err != nil {
+			return fmt.Errorf("sign run %d: %w", playerNum, err)
+		}
+	}
+	return nil
+}
+
+// signUnsigned signs the runs saved before scores were signed, it only runs with the migration that adds signatures
+// so that from then on a missing signature means someone removed it
+func (store *SQLiteStore) signUnsigned(ctx context.Context, tx sqlConn) error {
+	rows, err := tx.QueryContext(ctx, "SELECT player_num FROM players WHERE signature IS NULL")
+	if err != nil {
+		return fmt.Errorf("sign old runs: %w", err)
+	}
+	var unsigned []int
+	for rows.Next() {
+		var playerNum int
+		if err := rows.Scan(&playerNum); err != nil {
+			rows.Close()
+			return fmt.Errorf("sign old runs: %w", err)
+		}
+		unsigned = append(unsigned, playerNum)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sign old runs: %w", err)
+	}
+	for _, playerNum := range unsigned {
+		if err := store.sign(ctx, tx, playerNum, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckSignatures returns a problem for every run whose signature does not match it
+func (store *SQLiteStore) CheckSignatures(ctx context.Context) ([]string, error) {
+	rows, err := store.conn().QueryContext(ctx, signedRecordSQL+" ORDER BY players.player_num")
+	if err != nil {
+		return nil, fmt.Errorf("check signatures: %w", err)
+	}
+	signed, err := scanSignedRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("check signatures: %w", err)
+	}
+	var problems []string
+	for _, row := range signed {
+		if row.signature == "" {
+			problems = append(problems, fmt.Sprintf("run %d by %s (score %d) is not signed", row.playerNum, row.record.player, row.record.score))
+		} else if !store.key.verify(row.record, row.signature) {
+			problems = append(problems, fmt.Sprintf("run %d by %s (score %d) does not match its signature", row.playerNum, row.record.player, row.record.score))
+		}
+	}
+	return problems, nil
+}
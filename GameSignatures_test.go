@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// withoutKeyEnv runs the test with the key coming from the key file
+func withoutKeyEnv(t *testing.T) {
+	saved, set := os.LookupEnv(keyEnvVar)
+	os.Unsetenv(keyEnvVar)
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(keyEnvVar, saved)
+		}
+	})
+}
+
+func TestScoreKeyMadeOnceByManyCopies(t *testing.T) {
+	withoutKeyEnv(t)
+	dbfile := filepath.Join(t.TempDir(), "scores.db")
+	keys := make([]ScoreKey, 8)
+	errs := make([]error, len(keys))
+	var wait sync.WaitGroup
+	for i := range keys {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			keys[i], errs[i] = loadScoreKey(dbfile)
+		}(i)
+	}
+	wait.Wait()
+	for i := range keys {
+		if errs[i] != nil || len(keys[i]) != 64 || string(keys[i]) != string(keys[0]) {
+			t.Fatalf("copy %d got key %q %v, copy 0 got %q", i, keys[i], errs[i], keys[0])
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(dbfile), keyFileName+"*"))
+	if len(files) != 1 {
+		t.Fatalf("left %v behind", files)
+	}
+}
+
+func TestScoreKeyNeverEmpty(t *testing.T) {
+	withoutKeyEnv(t)
+	dbfile := filepath.Join(t.TempDir(), "scores.db")
+	file := filepath.Join(filepath.Dir(dbfile), keyFileName)
+	if err := ioutil.WriteFile(file, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := loadScoreKey(dbfile); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Fatalf("an empty key file gave key %q %v", key, err)
+	}
+
+	// a key file still being written by an older copy of the game is waited for
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(3 * keyWait)
+		ioutil.WriteFile(file, []byte("secret\n"), 0600)
+	}()
+	if key, err := loadScoreKey(dbfile); err != nil || string(key) != "secret" {
+		t.Fatalf("got key %q %v", key, err)
+	}
+}
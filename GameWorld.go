@@ -0,0 +1,714 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+const (
+	ScreenWidth   = 1000
+	ScreenHeight  = 750
+	WallThickness = 10
+	InfoBarHeight = 40
+	numEnemies    = 3
+	maxDogs       = 2 // a second player brings a second dog
+	khaiValue     = 200
+	sophiaValue   = 500
+)
+
+var (
+	xStart     = 30
+	yStart     = 70
+	deadSprite = -9999
+	pauseItems = []string{"Resume", "Quit"}
+
+	// where each dog stands on the title screen, a run starts from there, and where it comes back after losing a life
+	titleSpots = [maxDogs][2]int{{400, 100}, {500, 100}}
+	homeSpots  = [maxDogs][2]int{{xStart, yStart}, {xStart + 80, yStart}}
+)
+
+// Picture is all the game rules need to know about an image, its size. The game's pictures are ebiten images,
+// a world that is only simulated, like a replay being checked, makes do with sizes.
+type Picture interface {
+	Size() (width int, height int)
+}
+
+// pictureSize is a Picture without any pixels
+type pictureSize struct {
+	width  int
+	height int
+}
+
+func (size pictureSize) Size() (int, int) {
+	return size.width, size.height
+}
+
+// World is the part of the game that follows the rules, everything that happens in a run and nothing about
+// drawing it or saving it. Given the same seed, settings and input it always plays out the same way.
+type World struct {
+	dogs         [maxDogs]Dog
+	numDogs      int // 2 in a co-op run
+	khaiSprite   [numEnemies]Sprite
+	sophiaSprite [numEnemies]Sprite
+	level        [6]Level
+	currentLevel int
+	counter      int
+	paused       bool
+	pauseCursor  int
+	outOfBounds  bool
+	score        int // the team's score, every dog's score added up
+	moveConfig   MoveConfig
+	difficulty   Difficulty
+	aimWithMouse bool
+	rng          *rand.Rand // seeded from the session, so the toddlers do the same thing when a run is replayed
+}
+
+// Dog is one player's dog, along with the score and input that are that player's alone
+type Dog struct {
+	Sprite
+	score     int
+	frame     InputFrame
+	prevFrame InputFrame
+	homeX     int
+	homeY     int
+}
+
+func (dog *Dog) justPressed(action Action) bool {
+	return dog.frame.Held(action) && !dog.prevFrame.Held(action)
+}
+
+// tickEvents is what happened in one tick of play that the game needs to save or show
+type tickEvents struct {
+	cleared int  // the level that was just cleared, 0 if none was
+	died    bool // the last life was lost
+}
+
+// startRun puts the world where every run starts, so a replay of the run can start from exactly the same place
+func (world *World) startRun(seed int64) {
+	world.rng = rand.New(rand.NewSource(seed))
+	world.counter = 0
+	world.paused, world.pauseCursor = false, 0
+	world.outOfBounds = false
+	world.score = 0
+
+	// the dogs start where they stood on the title screen
+	for i := 0; i < maxDogs; i++ {
+		world.dogs[i] = Dog{
+			Sprite: Sprite{pict: world.dogs[i].pict, xLoc: titleSpots[i][0], yLoc: titleSpots[i][1], lives: 3, alive: i < world.numDogs,
+				Weapon: Weapon{pict: world.dogs[i].Weapon.pict}},
+			homeX: homeSpots[i][0], homeY: homeSpots[i][1],
+		}
+	}
+	for i := 0; i < numEnemies; i++ {
+		world.khaiSprite[i] = Sprite{pict: world.khaiSprite[i].pict, lives: 2, alive: true,
+			Weapon: Weapon{pict: world.khaiSprite[i].Weapon.pict}}
+		world.sophiaSprite[i] = Sprite{pict: world.sophiaSprite[i].pict, lives: 1, alive: true,
+			Weapon: Weapon{pict: world.sophiaSprite[i].Weapon.pict}}
+	}
+	setEnemyLocation(world)
+	world.currentLevel = 1
+}
+
+// nextFrame starts a tick with the input each player held down during it, player one's first
+func (world *World) nextFrame(frames ...InputFrame) {
+	world.counter++
+	for i := 0; i < maxDogs; i++ {
+		world.dogs[i].prevFrame = world.dogs[i].frame
+		world.dogs[i].frame = InputFrame{}
+		if i < len(frames) {
+			world.dogs[i].frame = frames[i]
+		}
+	}
+}
+
+// frames is the input of this tick, one frame for each dog
+func (world *World) frames() [maxDogs]InputFrame {
+	var frames [maxDogs]InputFrame
+	for i := 0; i < world.numDogs; i++ {
+		frames[i] = world.dogs[i].frame
+	}
+	return frames
+}
+
+// pauseTick runs the pause key and menu, paused is true when the tick went to the menu instead of the game
+func (world *World) pauseTick() (paused bool, quit bool) {
+	if world.anyJustPressed(ActionPause) {
+		world.paused = !world.paused
+		world.pauseCursor = 0
+	} else if world.paused {
+		return true, world.pauseMenu()
+	}
+	return world.paused, false
+}
+
+func (world *World) pauseMenu() (quit bool) {
+	if world.anyJustPressed(ActionUp) && world.pauseCursor > 0 {
+		world.pauseCursor--
+	} else if world.anyJustPressed(ActionDown) && world.pauseCursor < len(pauseItems)-1 {
+		world.pauseCursor++
+	}
+	if world.anyJustPressed(ActionConfirm) {
+		if pauseItems[world.pauseCursor] == "Quit" {
+			return true
+		}
+		world.paused = false
+	}
+	return false
+}
+
+// playTick moves everything on a level by one tick
+func (world *World) playTick() tickEvents {
+	var events tickEvents
+	weaponOrEnemyOut(world)
+	world.outOfBounds = false
+	for i := 0; i < world.numDogs; i++ {
+		dog := &world.dogs[i]
+		if !dog.alive {
+			continue
+		}
+		playerMovement(world, dog)
+		if outOfBounds(dog.pict, dog.xLoc, dog.yLoc) {
+			world.outOfBounds = true
+			resetPlayer(world, dog)
+		}
+	}
+
+	for i := 0; i < numEnemies; i++ {
+		world.sophiaSprite[i] = enemyShooting(world.sophiaSprite[i], world)
+		if world.sophiaSprite[i].activeShot {
+			shootSpeed := 8.0
+			moveShot(&world.sophiaSprite[i].Weapon, shootSpeed)
+			if outOfBounds(world.sophiaSprite[i].Weapon.pict, world.sophiaSprite[i].Weapon.dx, world.sophiaSprite[i].Weapon.dy) {
+				world.sophiaSprite[i].activeShot = false
+			}
+		}
+	}
+
+	for d := 0; d < world.numDogs; d++ {
+		dog := &world.dogs[d]
+		if !dog.alive {
+			continue
+		}
+		isShooting(world, dog)
+		if !dog.activeShot {
+			continue
+		}
+		shootSpeed := 8.0
+		moveShot(&dog.Weapon, shootSpeed)
+		if outOfBounds(dog.Weapon.pict, dog.Weapon.dx, dog.Weapon.dy) {
+			dog.activeShot = false
+		}
+
+		for i := 0; i < numEnemies; i++ {
+			enemyHit(world.khaiSprite[i], dog)
+			if dog.Weapon.enemyShot {
+				world.khaiSprite[i].lives--
+				dog.Weapon.enemyShot = false
+				dog.activeShot = false
+				world.addScore(dog, khaiValue)
+				if world.khaiSprite[i].lives <= 0 {
+					world.khaiSprite[i].alive = false
+					world.addScore(dog, 300)
+				}
+			}
+			enemyHit(world.sophiaSprite[i], dog)
+			if dog.Weapon.enemyShot {
+				world.sophiaSprite[i].lives--
+				dog.Weapon.enemyShot = false
+				dog.activeShot = false
+				world.addScore(dog, sophiaValue)
+				if world.sophiaSprite[i].lives <= 0 {
+					world.sophiaSprite[i].alive = false
+				}
+			}
+
+			if outOfBounds(world.khaiSprite[i].Weapon.pict, world.khaiSprite[i].Weapon.dx, world.khaiSprite[i].Weapon.dy) {
+				world.khaiSprite[i].activeShot = false
+			}
+		}
+	} // end of shot handler loop
+
+	for i := 0; i < numEnemies; i++ {
+		world.sophiaSprite[i] = enemyMovement(world.sophiaSprite[i], world)
+		world.khaiSprite[i] = enemyMovement(world.khaiSprite[i], world)
+	}
+	hitMaze(world)
+
+	// if you beat a level
+	for i := 0; i < numEnemies; i++ {
+		if world.sophiaSprite[0].alive == false && world.sophiaSprite[1].alive == false && world.sophiaSprite[2].alive == false &&
+			world.khaiSprite[0].alive == false && world.khaiSprite[1].alive == false && world.khaiSprite[2].alive == false {
+			for d := 0; d < world.numDogs; d++ {
+				world.dogs[d].xLoc = world.dogs[d].homeX
+				world.dogs[d].yLoc = world.dogs[d].homeY
+			}
+			setEnemyLocation(world)
+			if world.currentLevel == 3 { // set positions for end screen effect if game was beat
+				endScreen(world)
+			}
+			events.cleared = world.currentLevel
+			world.currentLevel++
+
+			for i := 0; i < numEnemies; i++ {
+				world.khaiSprite[i].alive = true
+				world.sophiaSprite[i].alive = true
+			}
+		}
+	}
+
+	// a dog whose last life is gone is out, in co-op the other one plays on without it
+	for d := 0; d < world.numDogs; d++ {
+		if world.dogs[d].alive && world.dogs[d].lives < 0 {
+			world.dogs[d].alive = false
+			world.dogs[d].activeShot = false
+		}
+	}
+	if world.lost() { // if you died
+		endScreen(world)
+		events.died = true
+		world.currentLevel = 4
+	}
+	return events
+}
+
+// lost is true once every dog in the run is out of lives
+func (world *World) lost() bool {
+	for i := 0; i < world.numDogs; i++ {
+		if world.dogs[i].alive {
+			return false
+		}
+	}
+	return true
+}
+
+// livesLeft adds up the lives the dogs have left, a dog that is out has none
+func (world *World) livesLeft() int {
+	lives := 0
+	for i := 0; i < world.numDogs; i++ {
+		if world.dogs[i].lives > 0 {
+			lives += world.dogs[i].lives
+		}
+	}
+	return lives
+}
+
+// addScore gives the points to the dog that earned them and to the team
+func (world *World) addScore(dog *Dog, points int) {
+	dog.score += points
+	world.score += points
+}
+
+// endScreen lines everyone up along the bottom for the game over screen
+func endScreen(world *World) {
+	world.khaiSprite[0].xLoc = 50
+	world.khaiSprite[0].yLoc = ScreenHeight - 100
+	world.sophiaSprite[0].xLoc = 150
+	world.sophiaSprite[0].yLoc = ScreenHeight - 100
+	for i := 0; i < world.numDogs; i++ {
+		world.dogs[i].xLoc = 275 + 100*i
+		world.dogs[i].yLoc = ScreenHeight - 100
+	}
+}
+
+type Level struct {
+	mazeWall [10]Wall
+	maxWall  int
+	level    int
+}
+
+type Wall struct {
+	xLoc int
+	yLoc int
+	pict Picture
+}
+
+type Weapon struct {
+	pict      Picture
+	dx        int
+	dy        int
+	dirX      float64 // unit vector the shot travels along
+	dirY      float64
+	xFrac     float64
+	yFrac     float64
+	enemyShot bool
+}
+
+type Sprite struct {
+	pict       Picture
+	xLoc       int
+	yLoc       int
+	dx         int
+	dy         int
+	Weapon     Weapon
+	activeShot bool
+	lives      int
+	alive      bool
+	hitWall    bool
+	motion     Motion
+	target     int // the dog an enemy is chasing
+}
+
+func resetPlayer(world *World, dog *Dog) {
+	dog.xLoc = dog.homeX
+	dog.yLoc = dog.homeY
+	dog.motion.stop()
+	world.addScore(dog, -100)
+	dog.lives--
+}
+
+func outOfBounds(picture Picture, xLoc int, yLoc int) bool {
+	pictureWidth, pictureHeight := picture.Size()
+	if xLoc <= WallThickness ||
+		xLoc+pictureWidth >= ScreenWidth-WallThickness ||
+		yLoc <= WallThickness+InfoBarHeight ||
+		yLoc+pictureHeight >= ScreenHeight-WallThickness {
+		return true
+	}
+	return false
+}
+
+func weaponOrEnemyOut(world *World) {
+	for i := 0; i < numEnemies; i++ {
+		if world.khaiSprite[i].alive == false {
+			world.khaiSprite[i].xLoc = deadSprite
+			world.khaiSprite[i].yLoc = deadSprite
+		}
+		if world.sophiaSprite[i].alive == false {
+			world.sophiaSprite[i].xLoc = deadSprite
+			world.sophiaSprite[i].yLoc = deadSprite
+		}
+	}
+	for i := 0; i < world.numDogs; i++ {
+		if world.dogs[i].alive == false {
+			world.dogs[i].xLoc = deadSprite
+			world.dogs[i].yLoc = deadSprite
+		}
+		if world.dogs[i].activeShot == false {
+			world.dogs[i].Weapon.dy = -3000
+			world.dogs[i].Weapon.dx = -3000
+		}
+	}
+}
+
+func endMovement(world *World) {
+	speed := 3
+	spriteW, _ := world.khaiSprite[0].pict.Size()
+	world.sophiaSprite[0].xLoc += speed
+	world.khaiSprite[0].xLoc += speed
+	for i := 0; i < world.numDogs; i++ {
+		world.dogs[i].xLoc += speed
+		if world.dogs[i].xLoc-spriteW > ScreenWidth {
+			world.dogs[i].xLoc = 0
+		}
+	}
+
+	if world.sophiaSprite[0].xLoc-spriteW > ScreenWidth {
+		world.sophiaSprite[0].xLoc = 0
+	}
+	if world.khaiSprite[0].xLoc-spriteW > ScreenWidth {
+		world.khaiSprite[0].xLoc = 0
+	}
+}
+
+func hitMaze(world *World) {
+	ammoWidth, ammoHeight := world.dogs[0].Weapon.pict.Size()
+	playerWidth, playerHeight := world.dogs[0].pict.Size()
+
+	for i := 0; i < world.level[world.currentLevel].maxWall; i++ {
+		wallWidth, wallHeight := world.level[world.currentLevel].mazeWall[i].pict.Size()
+		xPict, yPict := getWallLocation(world.level[world.currentLevel].mazeWall[i])
+
+		// if player or player's ammo hits maze wall - disappear or lose a life
+		for d := 0; d < world.numDogs; d++ {
+			dog := &world.dogs[d]
+			if !dog.alive {
+				continue
+			}
+			if dog.Weapon.dx > xPict && dog.Weapon.dx < xPict+wallWidth &&
+				dog.Weapon.dy > yPict && dog.Weapon.dy < yPict+wallHeight ||
+				dog.Weapon.dx+ammoWidth > xPict && dog.Weapon.dx+ammoWidth < xPict+wallWidth &&
+					dog.Weapon.dy+ammoHeight > yPict && dog.Weapon.dy+ammoHeight < yPict+wallHeight {
+				dog.activeShot = false
+			}
+			if dog.xLoc > xPict && dog.xLoc < xPict+wallWidth &&
+				dog.yLoc > yPict && dog.yLoc < yPict+wallHeight ||
+				dog.xLoc+playerWidth > xPict && dog.xLoc+playerWidth < xPict+wallWidth &&
+					dog.yLoc+playerHeight > yPict && dog.yLoc+playerHeight < yPict+wallHeight {
+				resetPlayer(world, dog)
+			}
+		}
+
+		enemyWidth, enemyHeight := world.khaiSprite[0].pict.Size()
+		for i := 0; i < numEnemies; i++ {
+			// if enemies hit maze wall - disappear, the dog it was chasing gets the points once even across two walls
+			if world.sophiaSprite[i].alive && (world.sophiaSprite[i].xLoc > xPict && world.sophiaSprite[i].xLoc < xPict+wallWidth &&
+				world.sophiaSprite[i].yLoc > yPict && world.sophiaSprite[i].yLoc < yPict+wallHeight ||
+				world.sophiaSprite[i].xLoc+enemyWidth > xPict && world.sophiaSprite[i].xLoc+enemyWidth < xPict+wallWidth &&
+					world.sophiaSprite[i].yLoc+enemyHeight > yPict && world.sophiaSprite[i].yLoc+enemyHeight < yPict+wallHeight) {
+				world.sophiaSprite[i].alive = false
+				world.addScore(&world.dogs[world.sophiaSprite[i].target], sophiaValue/2)
+			}
+			if world.khaiSprite[i].alive && (world.khaiSprite[i].xLoc > xPict && world.khaiSprite[i].xLoc < xPict+wallWidth &&
+				world.khaiSprite[i].yLoc > yPict && world.khaiSprite[i].yLoc < yPict+wallHeight ||
+				world.khaiSprite[i].xLoc+enemyWidth > xPict && world.khaiSprite[i].xLoc+enemyWidth < xPict+wallWidth &&
+					world.khaiSprite[i].yLoc+enemyHeight > yPict && world.khaiSprite[i].yLoc+enemyHeight < yPict+wallHeight) {
+				world.khaiSprite[i].alive = false
+				world.addScore(&world.dogs[world.khaiSprite[i].target], khaiValue/2)
+			}
+
+			// if enemy shots hit maze wall - disappear
+			if world.sophiaSprite[i].Weapon.dx > xPict && world.sophiaSprite[i].Weapon.dx < xPict+wallWidth &&
+				world.sophiaSprite[i].Weapon.dy > yPict && world.sophiaSprite[i].Weapon.dy < yPict+wallHeight ||
+				world.sophiaSprite[i].Weapon.dx+ammoWidth > xPict && world.sophiaSprite[i].Weapon.dx+ammoWidth < xPict+wallWidth &&
+					world.sophiaSprite[i].Weapon.dy+ammoHeight > yPict && world.sophiaSprite[i].dy+ammoHeight < yPict+wallHeight {
+				world.sophiaSprite[i].activeShot = false
+			}
+
+			// player collision with enemy sprites
+			for d := 0; d < world.numDogs; d++ {
+				dog := &world.dogs[d]
+				if !dog.alive {
+					continue
+				}
+				if dog.xLoc > world.khaiSprite[i].xLoc && dog.xLoc < world.khaiSprite[i].xLoc+enemyHeight &&
+					dog.yLoc > world.khaiSprite[i].yLoc && (dog.yLoc) < world.khaiSprite[i].yLoc+enemyWidth ||
+					dog.xLoc+playerWidth > world.khaiSprite[i].xLoc && dog.xLoc+playerWidth < world.khaiSprite[i].xLoc+enemyHeight &&
+						dog.yLoc+playerHeight > world.khaiSprite[i].yLoc && dog.yLoc+playerHeight < world.khaiSprite[i].yLoc+enemyWidth {
+					resetPlayer(world, dog)
+				}
+				if dog.xLoc > world.sophiaSprite[i].xLoc && dog.xLoc < world.sophiaSprite[i].xLoc+enemyHeight &&
+					dog.yLoc > world.sophiaSprite[i].yLoc && (dog.yLoc) < world.sophiaSprite[i].yLoc+enemyWidth ||
+					dog.xLoc+playerWidth > world.sophiaSprite[i].xLoc && dog.xLoc+playerWidth < world.sophiaSprite[i].xLoc+enemyHeight &&
+						dog.yLoc+playerHeight > world.sophiaSprite[i].yLoc && dog.yLoc+playerHeight < world.sophiaSprite[i].yLoc+enemyWidth {
+					resetPlayer(world, dog)
+				}
+			}
+		}
+	}
+}
+
+// chase picks the dog an enemy goes after, the nearest one still in the game
+func chase(enemy Sprite, world *World) int {
+	target, nearest := 0, -1
+	for i := 0; i < world.numDogs; i++ {
+		if !world.dogs[i].alive {
+			continue
+		}
+		dx, dy := world.dogs[i].xLoc-enemy.xLoc, world.dogs[i].yLoc-enemy.yLoc
+		if distance := dx*dx + dy*dy; nearest < 0 || distance < nearest {
+			target, nearest = i, distance
+		}
+	}
+	return target
+}
+
+func enemyMovement(enemy Sprite, world *World) Sprite {
+	movementSpeed := 10
+	enemy.target = chase(enemy, world)
+	target := world.dogs[enemy.target]
+	if world.counter%world.difficulty.moveEvery == 0 {
+		if enemy.xLoc < target.xLoc {
+			enemy.xLoc += movementSpeed
+		} else {
+			enemy.xLoc -= movementSpeed
+		}
+		if enemy.yLoc < target.yLoc {
+			enemy.yLoc += movementSpeed
+		} else {
+			enemy.yLoc -= movementSpeed
+		}
+	}
+	return enemy
+}
+
+// justPressed is for the menus, which follow player one
+func (world *World) justPressed(action Action) bool {
+	return world.dogs[0].justPressed(action)
+}
+
+// anyJustPressed is for what either player can do, like pausing
+func (world *World) anyJustPressed(action Action) bool {
+	for i := 0; i < world.numDogs; i++ {
+		if world.dogs[i].justPressed(action) {
+			return true
+		}
+	}
+	return false
+}
+
+func playerMovement(world *World, dog *Dog) {
+	dx, dy := stepMotion(&dog.motion, world.moveConfig, dog.frame, world.counter)
+	dog.dx = dx
+	dog.dy = dy
+	dog.yLoc += dog.dy
+	dog.xLoc += dog.dx
+}
+
+// throw launches a shot from (xLoc, yLoc) toward (dirX, dirY), which does not need to be normalised
+func throw(weapon *Weapon, xLoc int, yLoc int, dirX float64, dirY float64) {
+	length := math.Hypot(dirX, dirY)
+	if length == 0 {
+		return
+	}
+	weapon.dx = xLoc
+	weapon.dy = yLoc
+	weapon.dirX = dirX / length
+	weapon.dirY = dirY / length
+	weapon.xFrac, weapon.yFrac = 0, 0
+}
+
+func moveShot(weapon *Weapon, speed float64) {
+	weapon.xFrac += weapon.dirX * speed
+	weapon.yFrac += weapon.dirY * speed
+	stepX, stepY := int(weapon.xFrac), int(weapon.yFrac)
+	weapon.dx += stepX
+	weapon.dy += stepY
+	weapon.xFrac -= float64(stepX)
+	weapon.yFrac -= float64(stepY)
+}
+
+func isShooting(world *World, dog *Dog) {
+	ammoHeight, ammoWidth := dog.Weapon.pict.Size()
+	playerWidth, playerHeight := dog.pict.Size()
+
+	dirX, dirY := 0.0, 0.0
+	if dog.justPressed(ActionShootRight) {
+		dirX = 1
+	} else if dog.justPressed(ActionShootLeft) {
+		dirX = -1
+	} else if dog.justPressed(ActionShootDown) {
+		dirY = 1
+	} else if dog.justPressed(ActionShootUp) {
+		dirY = -1
+	} else if world.aimWithMouse && dog.justPressed(ActionThrow) {
+		dirX = float64(dog.frame.aimX - (dog.xLoc + playerWidth/2))
+		dirY = float64(dog.frame.aimY - (dog.yLoc + playerHeight/2))
+	}
+	if dirX != 0 || dirY != 0 {
+		throw(&dog.Weapon, dog.xLoc+(ammoWidth), dog.yLoc+(ammoHeight), dirX, dirY)
+		dog.activeShot = true
+	}
+}
+
+func enemyShooting(enemy Sprite, world *World) Sprite {
+	ammoHeight, ammoWidth := world.dogs[0].Weapon.pict.Size()
+
+	if world.counter%world.difficulty.shootEvery == 0 {
+		dirX, dirY := 0.0, 0.0
+		if world.currentLevel == 3 { // on the last level the ninjas aim straight at you
+			target := world.dogs[chase(enemy, world)]
+			dirX = float64(target.xLoc - enemy.xLoc)
+			dirY = float64(target.yLoc - enemy.yLoc)
+		} else {
+			randDirection := world.rng.Intn(4)
+			if randDirection == 0 {
+				dirY = -1
+			} else if randDirection == 1 {
+				dirY = 1
+			} else if randDirection == 2 {
+				dirX = -1
+			} else {
+				dirX = 1
+			}
+		}
+		throw(&enemy.Weapon, enemy.xLoc+(ammoWidth), enemy.yLoc+(ammoHeight), dirX, dirY)
+		enemy.activeShot = true
+	}
+	return enemy
+}
+
+func enemyHit(enemy Sprite, dog *Dog) {
+	enemyH, enemyW := enemy.pict.Size()
+	ammoH, ammoW := dog.Weapon.pict.Size()
+	if dog.Weapon.dx > enemy.xLoc && dog.Weapon.dx < enemy.xLoc+enemyH &&
+		dog.Weapon.dy > enemy.yLoc && dog.Weapon.dy < enemy.yLoc+enemyW ||
+		dog.Weapon.dx+ammoH > enemy.xLoc && dog.Weapon.dx+ammoH < enemy.xLoc+enemyH &&
+			dog.Weapon.dy+ammoW > enemy.yLoc && dog.Weapon.dy+ammoW < enemy.yLoc+enemyW {
+		dog.Weapon.enemyShot = true
+	}
+}
+
+func setEnemyLocation(world *World) {
+	for i := 0; i < numEnemies; i++ {
+		enemyWidth, enemyHeight := world.khaiSprite[0].pict.Size()
+		min := 50
+		maxHeight := ScreenHeight - enemyHeight - WallThickness
+		maxWidth := ScreenWidth - enemyWidth - WallThickness
+		xKhai := world.rng.Intn(maxWidth-min) + min
+		yKhai := world.rng.Intn(maxHeight-min) + min
+		world.khaiSprite[i].xLoc = xKhai
+		world.khaiSprite[i].yLoc = yKhai
+		xSophia := world.rng.Intn(maxWidth-min) + min
+		ySophia := world.rng.Intn(maxHeight-min) + min
+		world.sophiaSprite[i].xLoc = xSophia
+		world.sophiaSprite[i].yLoc = ySophia
+	}
+}
+
+func setWallLocation(x int, y int) (xD int, yD int) {
+	return x, y
+}
+
+func getWallLocation(wall Wall) (x int, y int) {
+	return wall.xLoc, wall.yLoc
+}
+
+func setMaze(world *World, makeWall func(width int, height int) Picture) {
+
+	// level 1
+	world.level[1].level = 1
+	world.level[1].maxWall = 5
+	world.level[1].mazeWall[0].pict = makeWall(WallThickness, 500)
+	world.level[1].mazeWall[1].pict = makeWall(610, WallThickness)
+	world.level[1].mazeWall[2].pict = makeWall(WallThickness, 360)
+	world.level[1].mazeWall[3].pict = makeWall(WallThickness, 360)
+	world.level[1].mazeWall[4].pict = makeWall(WallThickness, 150)
+	world.level[1].mazeWall[0].xLoc, world.level[1].mazeWall[0].yLoc = setWallLocation(200, 0)
+	world.level[1].mazeWall[1].xLoc, world.level[1].mazeWall[1].yLoc = setWallLocation(200, 200)
+	world.level[1].mazeWall[2].xLoc, world.level[1].mazeWall[2].yLoc = setWallLocation(400, 390)
+	world.level[1].mazeWall[3].xLoc, world.level[1].mazeWall[3].yLoc = setWallLocation(600, 200)
+	world.level[1].mazeWall[4].xLoc, world.level[1].mazeWall[4].yLoc = setWallLocation(810, 390)
+
+	// level 2
+	world.level[2].level = 2
+	world.level[2].maxWall = 6
+	world.level[2].mazeWall[0].pict = makeWall(800, WallThickness)
+	world.level[2].mazeWall[0].xLoc, world.level[2].mazeWall[0].yLoc = setWallLocation(0, 200)
+	world.level[2].mazeWall[1].pict = makeWall(WallThickness, 180)
+	world.level[2].mazeWall[1].xLoc, world.level[2].mazeWall[1].yLoc = setWallLocation(790, 200)
+	world.level[2].mazeWall[2].pict = makeWall(WallThickness, 180)
+	world.level[2].mazeWall[2].xLoc, world.level[2].mazeWall[2].yLoc = setWallLocation(590, 370)
+	world.level[2].mazeWall[3].pict = makeWall(WallThickness, 180)
+	world.level[2].mazeWall[3].xLoc, world.level[2].mazeWall[3].yLoc = setWallLocation(390, 200)
+	world.level[2].mazeWall[4].pict = makeWall(WallThickness, 180)
+	world.level[2].mazeWall[4].xLoc, world.level[2].mazeWall[4].yLoc = setWallLocation(190, 370)
+	world.level[2].mazeWall[5].pict = makeWall(610, WallThickness)
+	world.level[2].mazeWall[5].xLoc, world.level[2].mazeWall[5].yLoc = setWallLocation(190, 540)
+
+	// level 3
+	world.level[3].level = 3
+	world.level[3].maxWall = 6
+	world.level[3].mazeWall[0].pict = makeWall(600, WallThickness)
+	world.level[3].mazeWall[0].xLoc, world.level[3].mazeWall[0].yLoc = setWallLocation(200, 200)
+	world.level[3].mazeWall[1].pict = makeWall(WallThickness, 360)
+	world.level[3].mazeWall[1].xLoc, world.level[3].mazeWall[1].yLoc = setWallLocation(200, 200)
+	world.level[3].mazeWall[2].pict = makeWall(WallThickness, 350)
+	world.level[3].mazeWall[2].xLoc, world.level[3].mazeWall[2].yLoc = setWallLocation(790, 200)
+	world.level[3].mazeWall[3].pict = makeWall(400, WallThickness)
+	world.level[3].mazeWall[3].xLoc, world.level[3].mazeWall[3].yLoc = setWallLocation(200, 550)
+	world.level[3].mazeWall[4].pict = makeWall(WallThickness, 210)
+	world.level[3].mazeWall[4].xLoc, world.level[3].mazeWall[4].yLoc = setWallLocation(400, 350)
+	world.level[3].mazeWall[5].pict = makeWall(210, WallThickness)
+	world.level[3].mazeWall[5].xLoc, world.level[3].mazeWall[5].yLoc = setWallLocation(590, 350)
+
+	// game starting window
+	world.level[0].level = 0
+	world.level[0].maxWall = 1
+	world.level[0].mazeWall[0].pict = makeWall(ScreenWidth, WallThickness)
+	world.level[0].mazeWall[0].xLoc, world.level[0].mazeWall[0].yLoc = setWallLocation(0, 200)
+
+	// end game
+	world.level[4].level = 4
+
+	world.currentLevel = 0
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	writeQueueSize = 64
+	writeBatchSize = 16
+	writeAttempts  = 5
+	writeBackoff   = 50 * time.Millisecond
+)
+
+// writeJob is one write handed to the ScoreWriter, jobs sharing a key in the same batch only keep the newest.
+// A job with load set instead of run is a Load.
+type writeJob struct {
+	seq  int
+	key  string
+	name string
+	run  func(ctx context.Context, store ScoreStore) error
+	load func(store ScoreStore) func()
+}
+
+// WriteStatus is what the ScoreWriter last told the game, done is the newest job it has finished with
+type WriteStatus struct {
+	done int
+	err  error
+}
+
+// ScoreWriter does the store writes on its own goroutine so a slow or locked database never stalls a frame
+type ScoreWriter struct {
+	store     ScoreStore
+	jobs      chan writeJob
+	status    chan WriteStatus
+	results   chan func()
+	finished  chan struct{}
+	submitted int // only touched by the game loop
+}
+
+func NewScoreWriter(store ScoreStore) *ScoreWriter {
+	writer := &ScoreWriter{
+		store:    store,
+		jobs:     make(chan writeJob, writeQueueSize),
+		status:   make(chan WriteStatus, 1),
+		results:  make(chan func(), writeQueueSize+writeBatchSize),
+		finished: make(chan struct{}),
+	}
+	go writer.work()
+	return writer
+}
+
+// Submit queues a write without waiting for it and returns its sequence number, an error means the queue is full
+func (writer *ScoreWriter) Submit(key string, name string, run func(ctx context.Context, store ScoreStore) error) (int, error) {
+	return writer.queue(writeJob{key: key, name: name, run: run})
+}
+
+// Load queues a job that hands what it reads or makes back to the game, like the profile a run starts with.
+// It runs after every write queued before it and on its own, never in a batch, so what it writes is saved
+// before anything is handed back. run makes its own store calls, see retryBusy, and returns what to do with
+// them on the game loop, see Results.
+func (writer *ScoreWriter) Load(name string, run func(store ScoreStore) func()) (int, error) {
+	return writer.queue(writeJob{name: name, load: run})
+}
+
+func (writer *ScoreWriter) queue(job writeJob) (int, error) {
+	job.seq = writer.submitted + 1
+	select {
+	case writer.jobs <- job:
+		writer.submitted = job.seq
+		return job.seq, nil
+	default:
+		return 0, fmt.Errorf("%s: write queue is full", job.name)
+	}
+}
+
+// Status returns the newest status if the worker sent one since the last call, it never blocks
+func (writer *ScoreWriter) Status() (WriteStatus, bool) {
+	select {
+	case status := <-writer.status:
+		return status, true
+	default:
+		return WriteStatus{}, false
+	}
+}
+
+// Results returns what the loads finished since the last call handed back, in the order they were queued
+func (writer *ScoreWriter) Results() []func() {
+	var results []func()
+	for {
+		select {
+		case apply := <-writer.results:
+			results = append(results, apply)
+		default:
+			return results
+		}
+	}
+}
+
+func (writer *ScoreWriter) pending(status WriteStatus) int {
+	return writer.submitted - status.done
+}
+
+// Close stops taking writes and waits for the queued ones to reach the store
+func (writer *ScoreWriter) Close() {
+	close(writer.jobs)
+	<-writer.finished
+}
+
+func (writer *ScoreWriter) work() {
+	defer close(writer.finished)
+	for job := range writer.jobs {
+		batch := []writeJob{job}
+	collect: // take whatever else is already waiting
+		for len(batch) < writeBatchSize {
+			select {
+			case next, ok := <-writer.jobs:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+
+		status := WriteStatus{done: batch[len(batch)-1].seq}
+		var writes []writeJob
+		for i, job := range batch {
+			if job.load == nil {
+				if job.key == "" || !newerJob(batch[i+1:], job.key) {
+					writes = append(writes, job)
+				}
+				continue
+			}
+			// the writes queued before a load are saved before it runs
+			writer.writeAll(writes, &status)
+			writes = nil
+			if apply := job.load(writer.store); apply != nil {
+				writer.hand(apply)
+			}
+		}
+		writer.writeAll(writes, &status)
+		writer.report(status)
+	}
+}
+
+func newerJob(batch []writeJob, key string) bool {
+	for _, job := range batch {
+		if job.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAll saves the writes in one transaction when the store can, otherwise one at a time.
+// A single write gets its transaction too, so trying it again after a busy database starts over from nothing.
+func (writer *ScoreWriter) writeAll(writes []writeJob, status *WriteStatus) {
+	if len(writes) == 0 {
+		return
+	}
+	batcher, ok := writer.store.(Batcher)
+	if !ok {
+		for _, job := range writes {
+			if err := writer.write(job); err != nil {
+				status.err = err
+			}
+		}
+		return
+	}
+	calls := make([]func(ctx context.Context, store ScoreStore) error, len(writes))
+	for i := range writes {
+		calls[i] = writes[i].run
+	}
+	var errs []error
+	err := retryBusy(func(ctx context.Context) error {
+		var err error
+		errs, err = batcher.Batch(ctx, calls)
+		return err
+	})
+	for _, jobErr := range errs {
+		if jobErr != nil && err == nil {
+			err = jobErr
+		}
+	}
+	if err != nil {
+		status.err = err
+	}
+}
+
+// write runs one job, backing off and trying again while the database is busy
+func (writer *ScoreWriter) write(job writeJob) error {
+	return retryBusy(func(ctx context.Context) error {
+		return job.run(ctx, writer.store)
+	})
+}
+
+// retryBusy runs a store call with its own dbContext, backing off and trying again while the database is busy
+func retryBusy(run func(ctx context.Context) error) error {
+	wait := writeBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := dbContext()
+		err := run(ctx)
+		cancel()
+		if err == nil || !isBusy(err) || attempt == writeAttempts {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// hand passes a load's result to the game loop, one the game is no longer there to pick up is dropped
+func (writer *ScoreWriter) hand(apply func()) {
+	select {
+	case writer.results <- apply:
+	default:
+		log.Println("score writer: a load finished with nobody waiting for it")
+	}
+}
+
+// report replaces any status the game has not picked up yet, keeping the first error so it is not lost
+func (writer *ScoreWriter) report(status WriteStatus) {
+	select {
+	case old := <-writer.status:
+		if status.err == nil {
+			status.err = old.err
+		}
+	default:
+	}
+	writer.status <- status
+}
@@ -0,0 +1,48 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestArcadeSessionAfterABusyBatch(t *testing.T) {
+	store := migratedStore(t)
+	ctx := context.Background()
+	profile, err := store.EnsureProfile(ctx, "AAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	playerNum, err := store.AddPlayer(ctx, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := Session{profileID: profile.id, playerNum: playerNum, startedAt: time.Now().Add(-time.Minute), endedAt: time.Now(),
+		difficulty: "Normal", mode: ModeArcade, seed: 7, version: GameVersion, ticks: 600, outcome: OutcomeLost}
+
+	writer := NewScoreWriter(store)
+	release := holdWriter(writer)
+	writer.Submit("", "arcade session", arcadeSession(session))
+	busy := true
+	writer.Submit("", "busy once", func(ctx context.Context, store ScoreStore) error {
+		if busy { // rolls back the arcade session with the rest of the batch
+			busy = false
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		return nil
+	})
+	close(release)
+	writer.Close()
+
+	if status, ok := writer.Status(); !ok || status.err != nil {
+		t.Fatalf("status %+v %v", status, ok)
+	}
+	if got := dump(t, store.db, "SELECT player_num, mode, outcome, duration_ms FROM sessions"); got != "1|arcade|lost|10000" {
+		t.Fatalf("sessions after the batch was tried again: %q", got)
+	}
+}
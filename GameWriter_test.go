@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// countingStore counts the batches the writer hands the SQLite store
+type countingStore struct {
+	*SQLiteStore
+	batches int
+}
+
+func (store *countingStore) Batch(ctx context.Context, writes []func(ctx context.Context, store ScoreStore) error) ([]error, error) {
+	store.batches++
+	return store.SQLiteStore.Batch(ctx, writes)
+}
+
+// holdWriter queues a load that keeps the writer busy until release is closed, so what is queued next is one batch
+func holdWriter(writer *ScoreWriter) chan struct{} {
+	release := make(chan struct{})
+	writer.Load("hold", func(store ScoreStore) func() {
+		<-release
+		return nil
+	})
+	return release
+}
+
+func TestWriterBatchesInOneTransaction(t *testing.T) {
+	db, file := openTestDatabase(t)
+	ctx := context.Background()
+	sqlite := NewSQLiteStore(db, file, randomScoreKey())
+	if err := sqlite.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := sqlite.EnsureProfile(ctx, "Huy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var players [2]int
+	for i := range players {
+		if players[i], err = sqlite.AddPlayer(ctx, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := &countingStore{SQLiteStore: sqlite}
+	writer := NewScoreWriter(store)
+	release := holdWriter(writer)
+	score := func(playerNum, score int) func(ctx context.Context, store ScoreStore) error {
+		return func(ctx context.Context, store ScoreStore) error {
+			return store.UpdateScore(ctx, playerNum, score)
+		}
+	}
+	writer.Submit("score 1", "save score", score(players[0], 100))
+	writer.Submit("score 2", "save score", score(players[1], 200))
+	writer.Submit("", "fails halfway", func(ctx context.Context, store ScoreStore) error {
+		if _, err := store.AddPlayer(ctx, profile); err != nil {
+			return err
+		}
+		return errors.New("disk full")
+	})
+	writer.Submit("score 1", "save score", score(players[0], 150)) // replaces the first one
+	var loaded string
+	writer.Load("read back", func(store ScoreStore) func() {
+		got := dump(t, db, "SELECT player_num, player_score FROM players ORDER BY player_num")
+		return func() { loaded = got }
+	})
+	close(release)
+	writer.Close()
+
+	if store.batches != 1 {
+		t.Fatalf("the writes took %d batches, want 1", store.batches)
+	}
+	status, ok := writer.Status()
+	if !ok || status.done != 6 || status.err == nil || status.err.Error() != "disk full" {
+		t.Fatalf("status %+v %v", status, ok)
+	}
+	results := writer.Results()
+	if len(results) != 1 {
+		t.Fatalf("%d results, want the read back", len(results))
+	}
+	results[0]()
+	// the failed write took back its own player and nothing else, and the load saw the batch
+	if want := "1|150\n2|200"; loaded != want {
+		t.Fatalf("players after the batch:\n%s\nwant:\n%s", loaded, want)
+	}
+	if problems, err := sqlite.CheckSignatures(ctx); err != nil || len(problems) != 0 {
+		t.Fatalf("scores written in a batch are not signed: %v %v", problems, err)
+	}
+}
+
+func TestWriterWithoutBatches(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	profile, _ := store.EnsureProfile(ctx, "Ana")
+	playerNum, _ := store.AddPlayer(ctx, profile)
+
+	writer := NewScoreWriter(store)
+	release := holdWriter(writer)
+	var saved []int
+	for _, score := range []int{10, 20, 30} {
+		score := score
+		writer.Submit("score", "save score", func(ctx context.Context, store ScoreStore) error {
+			saved = append(saved, score)
+			return store.UpdateScore(ctx, playerNum, score)
+		})
+	}
+	close(release)
+	writer.Close()
+
+	if len(saved) != 1 || saved[0] != 30 {
+		t.Fatalf("saved %v, only the newest score for a key is written", saved)
+	}
+	entries, _, err := store.Leaderboard(ctx, LeaderboardQuery{window: WindowAllTime, limit: 5})
+	if err != nil || len(entries) != 1 || entries[0].score != 30 {
+		t.Fatalf("board %+v %v", entries, err)
+	}
+}
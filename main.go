@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -14,67 +17,80 @@ import (
 	"image/color"
 	_ "image/png"
 	"log"
+	"math"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
-type Level struct {
-	mazeWall [10]Wall
-	maxWall  int
-	level    int
-}
-
-type Wall struct {
-	xLoc int
-	yLoc int
-	pict *ebiten.Image
-}
-
-type Weapon struct {
-	pict      *ebiten.Image
-	dx        int
-	dy        int
-	direction string
-	enemyShot bool
-}
-
-type Sprite struct {
-	pict       *ebiten.Image
-	xLoc       int
-	yLoc       int
-	dx         int
-	dy         int
-	Weapon     Weapon
-	activeShot bool
-	lives      int
-	alive      bool
-	hitWall    bool
-}
-
 type InfoBar struct {
 	imageBar   *ebiten.Image
 	playerName string
-	score      int
 	playerNum  int
 }
 
 type Game struct {
-	playerSprite Sprite
-	khaiSprite   [numEnemies]Sprite
-	sophiaSprite [numEnemies]Sprite
+	World
 	drawOps      ebiten.DrawImageOptions
 	infoBar      InfoBar
 	wall         [4]Wall
-	outOfBounds  bool
-	level        [6]Level
-	currentLevel int
-	counter      int
+	input        InputSource
+	partnerInput InputSource // player two's keys and gamepad, only played in co-op
+	gamepads     *gamepadInput
+	oskCursor    int
+	notice       string
+	noticeTicks  int
+	bindings     Bindings
+	optionsOpen  bool
+	rebinding    bool
+	crosshair    *ebiten.Image
+	store        ScoreStore
+	scoresErr    error
+	board        LeaderboardView
+	profile      Profile
+	knownProfile Profile
+	profileKnown bool
+	lookedUpName string
+	lookingUp    bool   // a FindProfile for the typed name is on the writer
+	loading      string // what the title or game over screen waits on from the store, "" when nothing
+	session      Session
+	replay       Replay
+	profileOpen  bool
+	recent       []Session
+	bests        PersonalBests
+	scoresOpen   bool
+	scores       LeaderboardView
+	arcade       bool
+	initials     bool
+	highlight    int
+	writer       *ScoreWriter
+	client       *LeaderboardClient // nil without a leaderboard server
+	posting      PostStatus         // how posting the last finished run to the server went
+	saving       WriteStatus
+	saveDue      bool
+	stop         chan os.Signal
+	dbPath       string // the file the store keeps its data in
+	recovering   bool
+	backups      []string
+	lobbyOpen    bool
+	lobby        Lobby
+	net          *NetGame // nil unless playing online co-op
+	localDog     int      // the dog played on this machine online
+	netProblem   string   // why an online run ended early
+
+	recoveryProblem string
+	recoveryCursor  int
+
+	optionsCursor int
 }
 
 const (
 	GameTitle        = "Run Pupperooo~!!"
+	GameVersion      = "1.1.0"
 	GameInstructions = "You are a cute dog ready to take your tenth nap of the day but you are surrounded by playful toddlers. " +
 		"These toddlers \nare chasing you down because want to play with you and this is getting in the way of your nap. " +
 		"You must throw \nfrisbees at them to make them go away otherwise they will shoot at you with their squirt guns and you HATE squirt guns.\n" +
@@ -82,425 +98,655 @@ const (
 		"Your frisbee has magical powers and make toddlers fall asleep without hurting them. Hit all the toddlers and move \nonto the next level. " +
 		"Once all the levels are completed, you are finally able to take your nap. " +
 		"You will have three \nlives. " +
-		"and if you get shot with a squirt gun or run into the wall, you will lose a live \n" +
-		"\n** Controls ** - on the keyboard press the following\n" +
-		"        'W' - SHOOT UP\n        'S' - SHOOT DOWN\n        'A' - SHOOT RIGHT\n        'D' - SHOOT RIGHT\n" +
-		"        'UP ARROW' - MOVE UP\n        'DOWN ARROW' - MOVE DOWN\n        'RIGHT ARROW' - MOVE RIGHT\n        'LEFT ARROW' - MOVE LEFT"
-	ScreenWidth       = 1000
-	ScreenHeight      = 750
-	WallThickness     = 10
-	InfoBarHeight     = 40
+		"and if you get shot with a squirt gun or run into the wall, you will lose a live \n"
 	TotalScreenHeight = ScreenHeight + InfoBarHeight
-	numEnemies        = 3
-	khaiValue         = 200
-	sophiaValue       = 500
+	autosaveTicks     = 30 * 60
+	arcadeTop         = 5
+	highlightTicks    = 150
 )
 
 var (
-	xStart        = 30
-	yStart        = 70
-	xKhai         int
-	yKhai         int
-	xSophia       int
-	ySophia       int
-	deadSprite    = -9999
-	playerText    string
-	textColor     color.RGBA
-	updateScore   = 0
-	randDirection int
+	playerText  string
+	textColor   color.RGBA
+	updateScore = 0
+	errGameQuit = errors.New("player quit the game")
+	oskKeys     = []string{
+		"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M",
+		"N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z", "-",
+		"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "SPC", "DEL", "OK",
+	}
 )
 
-func resetPlayer(game *Game) {
-	game.playerSprite.xLoc = xStart
-	game.playerSprite.yLoc = yStart
-	game.infoBar.score -= 100
-	game.playerSprite.lives--
-}
+const oskColumns = 13
 
-func outOfBounds(picture *ebiten.Image, xLoc int, yLoc int) bool {
-	pictureWidth, pictureHeight := picture.Size()
-	if xLoc <= WallThickness ||
-		xLoc+pictureWidth >= ScreenWidth-WallThickness ||
-		yLoc <= WallThickness+InfoBarHeight ||
-		yLoc+pictureHeight >= ScreenHeight-WallThickness {
-		return true
+// the colour each player's part of the info bar is drawn in, matching their dog
+var dogColors = [maxDogs]color.Color{color.White, colornames.Lightskyblue}
+
+func (game *Game) Update() error {
+	select {
+	case sig := <-game.stop:
+		log.Println("got", sig, "- shutting down")
+		return errGameQuit
+	default:
 	}
-	return false
-}
+	if game.net != nil && game.currentLevel != 0 && game.currentLevel != 4 {
+		if !netTick(game) {
+			return nil
+		}
+	} else {
+		game.nextFrame(game.input.Poll(), game.partnerInput.Poll())
+	}
+	for _, event := range game.gamepads.takeEvents() {
+		game.notice = event
+		game.noticeTicks = 120
+	}
+	if game.noticeTicks > 0 {
+		game.noticeTicks--
+	}
+	checkWrites(game)
 
-func weaponOrEnemyOut(game *Game) {
-	for i := 0; i < numEnemies; i++ {
-		if game.khaiSprite[i].alive == false {
+	cursorMode := ebiten.CursorModeVisible
+	if game.aimWithMouse && !game.paused && game.currentLevel != 0 && game.currentLevel != 4 {
+		cursorMode = ebiten.CursorModeHidden
+	}
+	if ebiten.CursorMode() != cursorMode {
+		ebiten.SetCursorMode(cursorMode)
+	}
+
+	if game.currentLevel != 0 && game.currentLevel != 4 {
+		game.replay.record(game.frames())
+		if paused, quit := game.pauseTick(); quit {
+			return errGameQuit
+		} else if paused {
+			return nil
+		}
+		game.session.ticks++
+		if game.currentLevel > game.session.level && game.currentLevel <= 3 {
+			game.session.level = game.currentLevel
+		}
+		if game.saveDue || game.session.ticks%autosaveTicks == 0 {
+			checkpoint(game)
+		}
+	}
+
+	if game.currentLevel == 0 || game.currentLevel == 4 {
+		for i := 1; i < numEnemies; i++ {
 			game.khaiSprite[i].xLoc = deadSprite
 			game.khaiSprite[i].yLoc = deadSprite
-		}
-		if game.sophiaSprite[i].alive == false {
 			game.sophiaSprite[i].xLoc = deadSprite
 			game.sophiaSprite[i].yLoc = deadSprite
 		}
+		if game.loading != "" {
+			return nil // see waitFor
+		}
 	}
-	if game.playerSprite.activeShot == false {
-		game.playerSprite.Weapon.dy = -3000
-		game.playerSprite.Weapon.dx = -3000
-	}
-}
-
-func endMovement(game *Game) {
-	speed := 3
-	spriteW, _ := game.khaiSprite[0].pict.Size()
-	game.sophiaSprite[0].xLoc += speed
-	game.khaiSprite[0].xLoc += speed
-	game.playerSprite.xLoc += speed
 
-	if game.sophiaSprite[0].xLoc-spriteW > ScreenWidth {
-		game.sophiaSprite[0].xLoc = 0
-	}
-	if game.khaiSprite[0].xLoc-spriteW > ScreenWidth {
-		game.khaiSprite[0].xLoc = 0
-	}
-	if game.playerSprite.xLoc-spriteW > ScreenWidth {
-		game.playerSprite.xLoc = 0
-	}
-}
+	if game.currentLevel != 0 && game.currentLevel != 4 {
+		events := game.playTick()
+		if events.cleared != 0 {
+			unlockLevel(game, events.cleared)
+			game.saveDue = true
+		}
+		if events.died {
+			saveScore(game)
+			endSession(game, OutcomeLost)
+			updateScore++
+		}
+	} else if game.currentLevel == 4 {
+		leaveNetGame(game)
+		if updateScore == 0 && !game.lost() {
+			saveScore(game)
+			endSession(game, OutcomeWon)
+			updateScore++
+		}
+		endMovement(&game.World)
+		if game.highlight > 0 {
+			game.highlight--
+		}
+		if game.initials {
+			typeName(game, func() {
+				if len(game.infoBar.playerName) > 0 {
+					saveArcadeScore(game)
+				}
+			})
+		} else if game.justPressed(ActionLeft) || game.justPressed(ActionRight) { // flip between all time, today and this week
+			step := Window(1)
+			if game.justPressed(ActionLeft) {
+				step = numWindows - 1
+			}
+			game.board.query.window = (game.board.query.window + step) % numWindows
+			refreshLater(game, &game.board, game.infoBar.playerNum)
+		}
 
-func hitMaze(game *Game) {
-	ammoWidth, ammoHeight := game.playerSprite.Weapon.pict.Size()
-	playerWidth, playerHeight := game.playerSprite.pict.Size()
+	} else { // if current level is 0 - start game window
+		game.khaiSprite[0].xLoc = 250
+		game.khaiSprite[0].yLoc = 100
+		for i := 0; i < game.numDogs; i++ {
+			game.dogs[i].xLoc = titleSpots[i][0]
+			game.dogs[i].yLoc = titleSpots[i][1]
+		}
+		game.sophiaSprite[0].xLoc = 700
+		game.sophiaSprite[0].yLoc = 100
 
-	for i := 0; i < game.level[game.currentLevel].maxWall; i++ {
-		wallWidth, wallHeight := game.level[game.currentLevel].mazeWall[i].pict.Size()
-		xPict, yPict := getWallLocation(game.level[game.currentLevel].mazeWall[i])
+		textColor.R = 0x80 + uint8(rand.Intn(0x7f))
+		textColor.G = 0x80 + uint8(rand.Intn(0x7f))
+		textColor.B = 0x80 + uint8(rand.Intn(0x7f))
+		textColor.A = 0xff
 
-		// if player or player's ammo hits maze wall - disappear or lose a life
-		if game.playerSprite.Weapon.dx > xPict && game.playerSprite.Weapon.dx < xPict+wallWidth &&
-			game.playerSprite.Weapon.dy > yPict && game.playerSprite.Weapon.dy < yPict+wallHeight ||
-			game.playerSprite.Weapon.dx+ammoWidth > xPict && game.playerSprite.Weapon.dx+ammoWidth < xPict+wallWidth &&
-				game.playerSprite.Weapon.dy+ammoHeight > yPict && game.playerSprite.Weapon.dy+ammoHeight < yPict+wallHeight {
-			game.playerSprite.activeShot = false
+		if game.recovering {
+			recoveryMenu(game)
+			return nil
+		}
+		if game.optionsOpen {
+			optionsMenu(game)
+			return nil
+		}
+		if game.profileOpen {
+			profileScreen(game)
+			return nil
+		}
+		if game.scoresOpen {
+			highScoreScreen(game)
+			return nil
 		}
-		if game.playerSprite.xLoc > xPict && game.playerSprite.xLoc < xPict+wallWidth &&
-			game.playerSprite.yLoc > yPict && game.playerSprite.yLoc < yPict+wallHeight ||
-			game.playerSprite.xLoc+playerWidth > xPict && game.playerSprite.xLoc+playerWidth < xPict+wallWidth &&
-				game.playerSprite.yLoc+playerHeight > yPict && game.playerSprite.yLoc+playerHeight < yPict+wallHeight {
-			resetPlayer(game)
+		if game.lobbyOpen {
+			lobbyScreen(game)
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
+			openHighScores(game)
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyTab) && game.hasName() {
+			openOptions(game)
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF1) && game.hasName() {
+			openProfile(game)
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+			toggleCoop(game)
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF4) && game.hasName() {
+			openLobby(game)
+			return nil
 		}
 
-		enemyWidth, enemyHeight := game.khaiSprite[0].pict.Size()
-		for i := 0; i < numEnemies; i++ {
-			// if enemies hit maze wall - disappear
-			if game.sophiaSprite[i].xLoc > xPict && game.sophiaSprite[i].xLoc < xPict+wallWidth &&
-				game.sophiaSprite[i].yLoc > yPict && game.sophiaSprite[i].yLoc < yPict+wallHeight ||
-				game.sophiaSprite[i].xLoc+enemyWidth > xPict && game.sophiaSprite[i].xLoc+enemyWidth < xPict+wallWidth &&
-					game.sophiaSprite[i].yLoc+enemyHeight > yPict && game.sophiaSprite[i].yLoc+enemyHeight < yPict+wallHeight {
-				game.sophiaSprite[i].alive = false
-				game.infoBar.score += (sophiaValue / 2)
-			}
-			if game.khaiSprite[i].xLoc > xPict && game.khaiSprite[i].xLoc < xPict+wallWidth &&
-				game.khaiSprite[i].yLoc > yPict && game.khaiSprite[i].yLoc < yPict+wallHeight ||
-				game.khaiSprite[i].xLoc+enemyWidth > xPict && game.khaiSprite[i].xLoc+enemyWidth < xPict+wallWidth &&
-					game.khaiSprite[i].yLoc+enemyHeight > yPict && game.khaiSprite[i].yLoc+enemyHeight < yPict+wallHeight {
-				game.khaiSprite[i].alive = false
-				game.infoBar.score += (khaiValue / 2)
+		typeName(game, func() {
+			playerText = game.infoBar.playerName
+			if len(playerText) > 0 {
+				startGame(game)
+			} else if game.numDogs > 1 {
+				game.notice = "Type a team name to play co-op"
+				game.noticeTicks = 120
+			} else {
+				startArcade(game)
 			}
+		})
+		if game.infoBar.playerName != game.lookedUpName && !game.lookingUp {
+			recognisePlayer(game)
+		}
 
-			// if enemy shots hit maze wall - disappear
-			if game.sophiaSprite[i].Weapon.dx > xPict && game.sophiaSprite[i].Weapon.dx < xPict+wallWidth &&
-				game.sophiaSprite[i].Weapon.dy > yPict && game.sophiaSprite[i].Weapon.dy < yPict+wallHeight ||
-				game.sophiaSprite[i].Weapon.dx+ammoWidth > xPict && game.sophiaSprite[i].Weapon.dx+ammoWidth < xPict+wallWidth &&
-					game.sophiaSprite[i].Weapon.dy+ammoHeight > yPict && game.sophiaSprite[i].dy+ammoHeight < yPict+wallHeight {
-				game.sophiaSprite[i].activeShot = false
-			}
+	}
+	return nil
+} // end of Update
 
-			// player collision with enemy sprites
-			if game.playerSprite.xLoc > game.khaiSprite[i].xLoc && game.playerSprite.xLoc < game.khaiSprite[i].xLoc+enemyHeight &&
-				game.playerSprite.yLoc > game.khaiSprite[i].yLoc && (game.playerSprite.yLoc) < game.khaiSprite[i].yLoc+enemyWidth ||
-				game.playerSprite.xLoc+playerWidth > game.khaiSprite[i].xLoc && game.playerSprite.xLoc+playerWidth < game.khaiSprite[i].xLoc+enemyHeight &&
-					game.playerSprite.yLoc+playerHeight > game.khaiSprite[i].yLoc && game.playerSprite.yLoc+playerHeight < game.khaiSprite[i].yLoc+enemyWidth {
-				resetPlayer(game)
-			}
-			if game.playerSprite.xLoc > game.sophiaSprite[i].xLoc && game.playerSprite.xLoc < game.sophiaSprite[i].xLoc+enemyHeight &&
-				game.playerSprite.yLoc > game.sophiaSprite[i].yLoc && (game.playerSprite.yLoc) < game.sophiaSprite[i].yLoc+enemyWidth ||
-				game.playerSprite.xLoc+playerWidth > game.sophiaSprite[i].xLoc && game.playerSprite.xLoc+playerWidth < game.sophiaSprite[i].xLoc+enemyHeight &&
-					game.playerSprite.yLoc+playerHeight > game.sophiaSprite[i].yLoc && game.playerSprite.yLoc+playerHeight < game.sophiaSprite[i].yLoc+enemyWidth {
-				resetPlayer(game)
+// scoreProblem keeps the game running when the store fails and remembers to tell the player,
+// the next store call that works means the scores are back
+func scoreProblem(game *Game, err error) {
+	if err != nil {
+		log.Println("scores unavailable:", err)
+		game.scoresErr = err
+		return
+	}
+	game.scoresErr = nil
+}
+
+// recognisePlayer looks up the name being typed so a returning player gets welcomed back,
+// a name typed while the last one is still being looked up waits for it
+func recognisePlayer(game *Game) {
+	name := game.infoBar.playerName
+	game.lookedUpName = name
+	game.profileKnown = false
+	if len(name) == 0 {
+		return
+	}
+	game.lookingUp = queueLoad(game, "find profile", func(store ScoreStore) func() {
+		var profile Profile
+		var known bool
+		err := retryBusy(func(ctx context.Context) error {
+			var err error
+			profile, known, err = store.FindProfile(ctx, name)
+			return err
+		})
+		return func() {
+			game.lookingUp = false
+			scoreProblem(game, err)
+			if game.lookedUpName == name {
+				game.knownProfile, game.profileKnown = profile, known
 			}
 		}
-	}
+	})
 }
 
-func enemyMovement(enemy Sprite, game *Game) Sprite {
-	movementSpeed := 10
-	if game.counter%200 == 0 {
-		if enemy.xLoc < game.playerSprite.xLoc {
-			enemy.xLoc += movementSpeed
-		} else {
-			enemy.xLoc -= movementSpeed
+// loadProfile makes sure the typed name has a profile and loads its settings on the writer,
+// then puts them in place and runs then on the game loop
+func loadProfile(game *Game, what string, then func()) {
+	name := game.infoBar.playerName
+	waitFor(game, what, func(store ScoreStore) func() {
+		profile, settings, err := ensureProfile(store, name)
+		return func() {
+			game.profile = profile
+			scoreProblem(game, err)
+			applySettings(game, settings)
+			then()
 		}
-		if enemy.yLoc < game.playerSprite.yLoc {
-			enemy.yLoc += movementSpeed
-		} else {
-			enemy.yLoc -= movementSpeed
+	})
+}
+
+// ensureProfile is loadProfile's part on the writer
+func ensureProfile(store ScoreStore, name string) (Profile, map[string]string, error) {
+	var profile Profile
+	var settings map[string]string
+	err := retryBusy(func(ctx context.Context) error {
+		var err error
+		if profile, err = store.EnsureProfile(ctx, name); err != nil {
+			return err
 		}
+		settings, err = store.LoadSettings(ctx, profile.id)
+		return err
+	})
+	return profile, settings, err
+}
+
+// waitFor queues a load the screen waits on, the title and game over screens ignore input and show what is
+// being loaded until it is in
+func waitFor(game *Game, what string, run func(store ScoreStore) func()) {
+	game.loading = what
+	queueLoad(game, what, func(store ScoreStore) func() {
+		apply := run(store)
+		return func() {
+			game.loading = ""
+			apply()
+		}
+	})
+}
+
+// queueLoad hands a load to the writer, what run returns is done on the game loop by checkWrites
+func queueLoad(game *Game, name string, run func(store ScoreStore) func()) bool {
+	if _, err := game.writer.Load(name, run); err != nil {
+		game.loading = ""
+		scoreProblem(game, err)
+		return false
 	}
-	return enemy
+	return true
+}
+
+// refreshLater reads a board again on the writer after every write queued so far,
+// a board whose query changed in the meantime waits for the read of the new one
+func refreshLater(game *Game, board *LeaderboardView, playerNum int) {
+	view := *board
+	query := board.query
+	queueLoad(game, "refresh scores", func(store ScoreStore) func() {
+		err := retryBusy(func(ctx context.Context) error {
+			return view.Refresh(ctx, store, playerNum)
+		})
+		return func() {
+			scoreProblem(game, err)
+			if err == nil && board.query == query {
+				board.entries, board.total = view.entries, view.total
+				board.mine, board.hasMine = view.mine, view.hasMine
+			}
+		}
+	})
 }
 
-func playerMovement(game *Game) {
-	playerspeed := 5
-	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
-		game.playerSprite.dx = -playerspeed
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
-		game.playerSprite.dx = playerspeed
-	} else if inpututil.IsKeyJustReleased(ebiten.KeyLeft) || inpututil.IsKeyJustReleased(ebiten.KeyRight) {
-		game.playerSprite.dx = 0
+func unlockLevel(game *Game, level int) {
+	if game.arcade { // unlocks belong to a profile and arcade runs have none
+		return
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
-		game.playerSprite.dy = -playerspeed
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
-		game.playerSprite.dy = playerspeed
-	} else if inpututil.IsKeyJustReleased(ebiten.KeyUp) || inpututil.IsKeyJustReleased(ebiten.KeyDown) {
-		game.playerSprite.dy = 0
+	unlock := "Cleared level " + strconv.Itoa(level)
+	if level == 3 {
+		unlock = "Beat the game"
+	}
+	profileID := game.profile.id
+	queueWrite(game, "", "unlock", func(ctx context.Context, store ScoreStore) error {
+		return store.Unlock(ctx, profileID, unlock)
+	})
+	if !game.profile.hasUnlock(unlock) {
+		game.profile.unlocks = append(game.profile.unlocks, unlock)
+		game.notice = "Unlocked: " + unlock
+		game.noticeTicks = 120
 	}
-	game.playerSprite.yLoc += game.playerSprite.dy
-	game.playerSprite.xLoc += game.playerSprite.dx
 }
 
-func isShooting(game *Game) {
-	ammoHeight, ammoWidth := game.playerSprite.Weapon.pict.Size()
+// toggleCoop switches between one dog and two, the second player gets the second gamepad if there is one
+func toggleCoop(game *Game) {
+	if game.numDogs == 1 {
+		game.numDogs = 2
+	} else {
+		game.numDogs = 1
+	}
+	game.gamepads.split = game.numDogs > 1
+}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyD) ||
-		inpututil.IsKeyJustPressed(ebiten.KeyA) ||
-		inpututil.IsKeyJustPressed(ebiten.KeyS) ||
-		inpututil.IsKeyJustPressed(ebiten.KeyW) {
+// startGame loads the player's profile and then saves the new run, the run begins once both are in
+func startGame(game *Game) {
+	game.infoBar.playerName = playerText
+	loadProfile(game, "Starting", func() {
+		if game.net != nil { // the host's rules win
+			game.net.start.apply(&game.World)
+		}
+		game.infoBar.playerName = game.profile.name
+		playerText = game.profile.name
 
-		game.playerSprite.Weapon.dx = game.playerSprite.xLoc + (ammoWidth)
-		game.playerSprite.Weapon.dy = game.playerSprite.yLoc + (ammoHeight)
-		game.playerSprite.activeShot = true
-	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyD) {
-		game.playerSprite.Weapon.direction = "right"
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyA) {
-		game.playerSprite.Weapon.direction = "left"
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyS) {
-		game.playerSprite.Weapon.direction = "down"
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyW) {
-		game.playerSprite.Weapon.direction = "up"
-	}
+		mode := ModeSolo
+		if game.numDogs > 1 {
+			mode = ModeCoop
+		}
+		profile, session := game.profile, newSession(game, mode)
+		if game.net != nil {
+			session.seed = game.net.start.seed
+		}
+		waitFor(game, "Starting", func(store ScoreStore) func() {
+			playerNum, err := addPlayer(store, profile)
+			session.playerNum = playerNum
+			if err == nil {
+				err = retryBusy(func(ctx context.Context) error {
+					return store.StartSession(ctx, &session)
+				})
+			}
+			return func() {
+				scoreProblem(game, err)
+				game.infoBar.playerNum = playerNum
+				game.session = session
+				beginRun(game)
+			}
+		})
+	})
 }
 
-func enemyShooting(enemy Sprite, game *Game) Sprite {
-	ammoHeight, ammoWidth := game.playerSprite.Weapon.pict.Size()
+// addPlayer adds a run for the profile on the writer
+func addPlayer(store ScoreStore, profile Profile) (int, error) {
+	var playerNum int
+	err := retryBusy(func(ctx context.Context) error {
+		var err error
+		playerNum, err = store.AddPlayer(ctx, profile)
+		return err
+	})
+	return playerNum, err
+}
 
-	if game.counter%100 == 0 {
-		randDirection = rand.Intn(4)
-		enemy.Weapon.dx = enemy.xLoc + (ammoWidth)
-		enemy.Weapon.dy = enemy.yLoc + (ammoHeight)
-		enemy.activeShot = true
-	}
-	if randDirection == 0 {
-		enemy.Weapon.direction = "up"
-	} else if randDirection == 1 {
-		enemy.Weapon.direction = "down"
-	} else if randDirection == 2 {
-		enemy.Weapon.direction = "left"
-	} else {
-		enemy.Weapon.direction = "right"
+// startArcade plays a run without a name, nothing is stored unless the score makes the high scores
+func startArcade(game *Game) {
+	game.arcade = true
+	game.profile = Profile{}
+	game.infoBar.playerNum = 0
+	game.session = newSession(game, ModeArcade)
+	beginRun(game)
+}
+
+func newSession(game *Game, mode string) Session {
+	session := Session{
+		profileID:  game.profile.id,
+		playerNum:  game.infoBar.playerNum,
+		startedAt:  time.Now(),
+		difficulty: game.difficulty.name,
+		mode:       mode,
+		seed:       time.Now().UnixNano(),
+		version:    GameVersion,
 	}
-	return enemy
+	return session
+}
+
+// beginRun starts the world from the session's seed and starts recording the run so it can be replayed
+func beginRun(game *Game) {
+	game.startRun(game.session.seed)
+	game.replay = newReplay(&game.World, game.session.seed)
 }
 
-func enemyHit(enemy Sprite, game *Game) {
-	enemyH, enemyW := enemy.pict.Size()
-	ammoH, ammoW := game.playerSprite.Weapon.pict.Size()
-	if game.playerSprite.Weapon.dx > enemy.xLoc && game.playerSprite.Weapon.dx < enemy.xLoc+enemyH &&
-		game.playerSprite.Weapon.dy > enemy.yLoc && game.playerSprite.Weapon.dy < enemy.yLoc+enemyW ||
-		game.playerSprite.Weapon.dx+ammoH > enemy.xLoc && game.playerSprite.Weapon.dx+ammoH < enemy.xLoc+enemyH &&
-			game.playerSprite.Weapon.dy+ammoW > enemy.yLoc && game.playerSprite.Weapon.dy+ammoW < enemy.yLoc+enemyW {
-		game.playerSprite.Weapon.enemyShot = true
+// saveArcadeScore stores a qualifying arcade run under the name typed at game over and highlights it on the board
+func saveArcadeScore(game *Game) {
+	name := strings.TrimSpace(game.infoBar.playerName)
+	if name == "" {
+		return
 	}
+	game.initials = false
+	waitFor(game, "Saving your score", func(store ScoreStore) func() {
+		var profile Profile
+		err := retryBusy(func(ctx context.Context) error {
+			var err error
+			profile, err = store.EnsureProfile(ctx, name)
+			return err
+		})
+		var playerNum int
+		if err == nil {
+			playerNum, err = addPlayer(store, profile)
+		}
+		return func() {
+			scoreProblem(game, err)
+			game.profile = profile
+			game.infoBar.playerName = profile.name
+			game.infoBar.playerNum = playerNum
+			saveArcadeRun(game)
+		}
+	})
 }
 
-func (game *Game) Update() error {
-	game.counter++
+// saveArcadeRun queues the arcade run's score, session and replay once it has a player number
+func saveArcadeRun(game *Game) {
+	queueScore(game)
+	game.session.profileID = game.profile.id
+	game.session.playerNum = game.infoBar.playerNum
+	queueWrite(game, "", "arcade session", arcadeSession(game.session))
+	queueReplay(game, game.session.playerNum, game.replay)
+	postRun(game)
+	game.board.query.window = WindowAllTime
+	refreshLater(game, &game.board, game.infoBar.playerNum)
+	game.highlight = highlightTicks
+}
 
-	if game.currentLevel == 0 || game.currentLevel == 4 {
-		for i := 1; i < numEnemies; i++ {
-			game.khaiSprite[i].xLoc = deadSprite
-			game.khaiSprite[i].yLoc = deadSprite
-			game.sophiaSprite[i].xLoc = deadSprite
-			game.sophiaSprite[i].yLoc = deadSprite
+// arcadeSession starts and finishes an arcade run's session in one write. A busy database rolls back the
+// whole batch and the writer runs it again, so every try starts from a copy without the rolled back row's id.
+func arcadeSession(session Session) func(ctx context.Context, store ScoreStore) error {
+	return func(ctx context.Context, store ScoreStore) error {
+		started := session
+		started.id = 0
+		if err := store.StartSession(ctx, &started); err != nil {
+			return err
 		}
+		return store.FinishSession(ctx, started)
 	}
+}
 
-	if game.currentLevel != 0 && game.currentLevel != 4 {
-		weaponOrEnemyOut(game)
-		playerMovement(game)
-		game.outOfBounds = outOfBounds(game.playerSprite.pict, game.playerSprite.xLoc, game.playerSprite.yLoc)
-		if game.outOfBounds == true {
-			resetPlayer(game)
-		}
+// endSession records how the run finished, it only counts the first time it is called for a run
+func endSession(game *Game, outcome string) {
+	if game.session.outcome != "" {
+		return
+	}
+	game.session = sessionSoFar(game)
+	game.session.endedAt = time.Now()
+	game.session.outcome = outcome
+	if game.arcade { // written by saveArcadeScore once the run has a name
+		return
+	}
+	session, replay := game.session, game.replay
+	queueWrite(game, "", "finish session", func(ctx context.Context, store ScoreStore) error {
+		return store.FinishSession(ctx, session)
+	})
+	queueReplay(game, session.playerNum, replay)
+	// read after the replay is checked, a run held for review leaves the board
+	refreshLater(game, &game.board, game.infoBar.playerNum)
+	postRun(game)
+}
 
-		for i := 0; i < numEnemies; i++ {
-			game.sophiaSprite[i] = enemyShooting(game.sophiaSprite[i], game)
-			if game.sophiaSprite[i].activeShot {
-				shootSpeed := 8
-				if game.sophiaSprite[i].Weapon.direction == "right" {
-					game.sophiaSprite[i].Weapon.dx += shootSpeed
-				} else if game.sophiaSprite[i].Weapon.direction == "left" {
-					game.sophiaSprite[i].Weapon.dx -= shootSpeed
-				} else if game.sophiaSprite[i].Weapon.direction == "up" {
-					game.sophiaSprite[i].Weapon.dy -= shootSpeed
-				} else if game.sophiaSprite[i].Weapon.direction == "down" {
-					game.sophiaSprite[i].Weapon.dy += shootSpeed
-				}
-				if outOfBounds(game.sophiaSprite[i].Weapon.pict, game.sophiaSprite[i].Weapon.dx, game.sophiaSprite[i].Weapon.dy) {
-					game.sophiaSprite[i].activeShot = false
-				}
+// postRun sends the finished run to the leaderboard server when the game has one
+func postRun(game *Game) {
+	if game.client == nil {
+		return
+	}
+	submission, err := newSubmission(game.infoBar.playerName, game.session, game.replay)
+	if err != nil {
+		log.Println("posting run:", err)
+		return
+	}
+	game.posting = PostStatus{key: submission.key, state: PostSending}
+	if !game.client.Submit(submission) {
+		game.posting.state, game.posting.message = PostRejected, "too many runs waiting to be posted"
+	}
+}
+
+// queueReplay sends the run's replay after its score and session, the store plays it back to check them.
+// It is a load so playing it back never holds up a batch of writes.
+func queueReplay(game *Game, playerNum int, replay Replay) {
+	queueLoad(game, "submit replay", func(store ScoreStore) func() {
+		var mismatch string
+		err := retryBusy(func(ctx context.Context) error {
+			var err error
+			mismatch, err = store.SubmitReplay(ctx, playerNum, replay)
+			return err
+		})
+		if err == nil && mismatch != "" {
+			log.Printf("run %d does not match its replay (%s), it is waiting for review", playerNum, mismatch)
+		}
+		return func() {
+			if err != nil {
+				scoreProblem(game, err)
 			}
 		}
+	})
+}
 
-		isShooting(game)
-		if game.playerSprite.activeShot {
-			shootSpeed := 8
-			if game.playerSprite.Weapon.direction == "right" {
-				game.playerSprite.Weapon.dx += shootSpeed
-			} else if game.playerSprite.Weapon.direction == "left" {
-				game.playerSprite.Weapon.dx -= shootSpeed
-			} else if game.playerSprite.Weapon.direction == "up" {
-				game.playerSprite.Weapon.dy -= shootSpeed
-			} else if game.playerSprite.Weapon.direction == "down" {
-				game.playerSprite.Weapon.dy += shootSpeed
-			}
-			if outOfBounds(game.playerSprite.Weapon.pict, game.playerSprite.Weapon.dx, game.playerSprite.Weapon.dy) {
-				game.playerSprite.activeShot = false
-			}
+// sessionSoFar is the running session with the score and lives as they are right now
+func sessionSoFar(game *Game) Session {
+	session := game.session
+	session.score = game.score
+	session.livesLeft = game.livesLeft()
+	return session
+}
 
-			for i := 0; i < numEnemies; i++ {
-				enemyHit(game.khaiSprite[i], game)
-				if game.playerSprite.Weapon.enemyShot {
-					game.khaiSprite[i].lives--
-					game.playerSprite.Weapon.enemyShot = false
-					game.playerSprite.activeShot = false
-					game.infoBar.score += khaiValue
-					if game.khaiSprite[i].lives <= 0 {
-						game.khaiSprite[i].alive = false
-						game.infoBar.score += 300
-					}
-				}
-				enemyHit(game.sophiaSprite[i], game)
-				if game.playerSprite.Weapon.enemyShot {
-					game.sophiaSprite[i].lives--
-					game.playerSprite.Weapon.enemyShot = false
-					game.playerSprite.activeShot = false
-					game.infoBar.score += sophiaValue
-					if game.sophiaSprite[i].lives <= 0 {
-						game.sophiaSprite[i].alive = false
-					}
-				}
+// checkpoint saves the run so far, so closing the window or a crash mid game loses little
+func checkpoint(game *Game) {
+	game.saveDue = false
+	if game.arcade {
+		return
+	}
+	queueScore(game)
+	session := sessionSoFar(game)
+	queueWrite(game, "session "+strconv.Itoa(session.id), "checkpoint", func(ctx context.Context, store ScoreStore) error {
+		return store.CheckpointSession(ctx, session)
+	})
+}
 
-				if outOfBounds(game.khaiSprite[i].Weapon.pict, game.khaiSprite[i].Weapon.dx, game.khaiSprite[i].Weapon.dy) {
-					game.khaiSprite[i].activeShot = false
-				}
+func saveScore(game *Game) {
+	if game.arcade {
+		score := game.score
+		waitFor(game, "Checking the high scores", func(store ScoreStore) func() {
+			var qualifies bool
+			err := retryBusy(func(ctx context.Context) error {
+				var err error
+				qualifies, err = arcadeQualifies(ctx, store, score, arcadeTop)
+				return err
+			})
+			return func() {
+				scoreProblem(game, err)
+				game.initials = qualifies
 			}
-		} // end of shot handler if statement
+		})
+		return
+	}
+	queueScore(game)
+}
 
-		for i := 0; i < numEnemies; i++ {
-			game.sophiaSprite[i] = enemyMovement(game.sophiaSprite[i], game)
-			game.khaiSprite[i] = enemyMovement(game.khaiSprite[i], game)
-		}
-		hitMaze(game)
+// queueScore hands the score to the writer, a newer score for the same run replaces one still waiting
+func queueScore(game *Game) {
+	playerNum, score := game.infoBar.playerNum, game.score
+	queueWrite(game, "score "+strconv.Itoa(playerNum), "save score", func(ctx context.Context, store ScoreStore) error {
+		return store.UpdateScore(ctx, playerNum, score)
+	})
+}
 
-		// if you beat a level
-		for i := 0; i < numEnemies; i++ {
-			if game.sophiaSprite[0].alive == false && game.sophiaSprite[1].alive == false && game.sophiaSprite[2].alive == false &&
-				game.khaiSprite[0].alive == false && game.khaiSprite[1].alive == false && game.khaiSprite[2].alive == false {
-				game.playerSprite.xLoc = xStart
-				game.playerSprite.yLoc = yStart
-				setEnemyLocation(game)
-				if game.currentLevel == 3 { // set positions for end screen effect if game was beat
-					game.khaiSprite[0].xLoc = 50
-					game.khaiSprite[0].yLoc = ScreenHeight - 100
-					game.sophiaSprite[0].xLoc = 150
-					game.sophiaSprite[0].yLoc = ScreenHeight - 100
-					game.playerSprite.xLoc = 275
-					game.playerSprite.yLoc = ScreenHeight - 100
-				}
-				game.currentLevel++
+func queueWrite(game *Game, key string, name string, run func(ctx context.Context, store ScoreStore) error) {
+	if _, err := game.writer.Submit(key, name, run); err != nil { // only queued so far, whether the store took it comes back through checkWrites
+		scoreProblem(game, err)
+	}
+}
 
-				for i := 0; i < numEnemies; i++ {
-					game.khaiSprite[i].alive = true
-					game.sophiaSprite[i].alive = true
-				}
-			}
+// checkWrites picks up what the writer has done since the last frame and puts what its loads read in place
+func checkWrites(game *Game) {
+	if status, ok := game.writer.Status(); ok {
+		game.saving = status
+		scoreProblem(game, status.err)
+	}
+	for game.client != nil {
+		status, ok := game.client.Status()
+		if !ok {
+			break
 		}
-
-		if game.playerSprite.lives < 0 { // if you died
-			game.khaiSprite[0].xLoc = 50
-			game.khaiSprite[0].yLoc = ScreenHeight - 100
-			game.sophiaSprite[0].xLoc = 150
-			game.sophiaSprite[0].yLoc = ScreenHeight - 100
-			game.playerSprite.xLoc = 275
-			game.playerSprite.yLoc = ScreenHeight - 100
-			UpdateScore(game.infoBar.score, game.infoBar.playerNum)
-			updateScore++
-			game.currentLevel = 4
+		if status.key == game.posting.key {
+			game.posting = status
 		}
+	}
+	for _, apply := range game.writer.Results() {
+		apply()
+	}
+}
 
-	} else if game.currentLevel == 4 {
-		if updateScore == 0 && game.playerSprite.lives >= 0 {
-			UpdateScore(game.infoBar.score, game.infoBar.playerNum)
-			updateScore++
+// typeName edits the name from the keyboard, or the on-screen keyboard with a gamepad, done runs on enter or OK
+// with the spaces around the name gone, so a name of only spaces is no name at all
+func typeName(game *Game, done func()) {
+	finish := func() {
+		game.infoBar.playerName = strings.TrimSpace(game.infoBar.playerName)
+		done()
+	}
+	game.infoBar.playerName += string(ebiten.InputChars())
+	if playerTyping(ebiten.KeyEnter) || playerTyping(ebiten.KeyKPEnter) {
+		finish()
+	} else if game.gamepads.connected() {
+		onScreenKeyboard(game, finish)
+	}
+	if playerTyping(ebiten.KeyBackspace) {
+		if len(game.infoBar.playerName) >= 1 {
+			game.infoBar.playerName = game.infoBar.playerName[:len(game.infoBar.playerName)-1]
 		}
-		endMovement(game)
+	}
+	if len(game.infoBar.playerName) >= 16 {
+		game.infoBar.playerName = game.infoBar.playerName[:len(game.infoBar.playerName)-1]
+	}
+}
 
-	} else { // if current level is 0 - start game window
-		game.khaiSprite[0].xLoc = 250
-		game.khaiSprite[0].yLoc = 100
-		game.playerSprite.xLoc = 400
-		game.playerSprite.yLoc = 100
-		game.sophiaSprite[0].xLoc = 700
-		game.sophiaSprite[0].yLoc = 100
+// hasName tells whether something other than spaces has been typed for the name
+func (game *Game) hasName() bool {
+	return strings.TrimSpace(game.infoBar.playerName) != ""
+}
 
-		textColor.R = 0x80 + uint8(rand.Intn(0x7f))
-		textColor.G = 0x80 + uint8(rand.Intn(0x7f))
-		textColor.B = 0x80 + uint8(rand.Intn(0x7f))
-		textColor.A = 0xff
+// onScreenKeyboard lets a gamepad type a name by moving a cursor over a grid of keys
+func onScreenKeyboard(game *Game, done func()) {
+	if game.justPressed(ActionLeft) && game.oskCursor%oskColumns > 0 {
+		game.oskCursor--
+	} else if game.justPressed(ActionRight) && game.oskCursor%oskColumns < oskColumns-1 {
+		game.oskCursor++
+	} else if game.justPressed(ActionUp) && game.oskCursor >= oskColumns {
+		game.oskCursor -= oskColumns
+	} else if game.justPressed(ActionDown) && game.oskCursor+oskColumns < len(oskKeys) {
+		game.oskCursor += oskColumns
+	}
 
-		game.infoBar.playerName += string(ebiten.InputChars())
-		if playerTyping(ebiten.KeyEnter) && len(playerText) > 0 || playerTyping(ebiten.KeyKPEnter) && len(playerText) > 0 {
-			game.infoBar.playerName = playerText
-			AddPlayerName(game.infoBar.playerName)
-			game.infoBar.playerNum = GetPlayerNum()
-			setEnemyLocation(game)
-			game.currentLevel++
-		}
-		if playerTyping(ebiten.KeyBackspace) {
+	if game.justPressed(ActionConfirm) {
+		switch oskKeys[game.oskCursor] {
+		case "OK":
+			done()
+		case "DEL":
 			if len(game.infoBar.playerName) >= 1 {
 				game.infoBar.playerName = game.infoBar.playerName[:len(game.infoBar.playerName)-1]
 			}
+		case "SPC":
+			game.infoBar.playerName += " "
+		default:
+			game.infoBar.playerName += oskKeys[game.oskCursor]
 		}
-		if len(game.infoBar.playerName) >= 16 {
-			game.infoBar.playerName = game.infoBar.playerName[:len(game.infoBar.playerName)-1]
-		}
-
-	}
-	return nil
-} // end of Update
-
-func setEnemyLocation(game *Game) {
-	for i := 0; i < numEnemies; i++ {
-		enemyWidth, enemyHeight := game.khaiSprite[0].pict.Size()
-		min := 50
-		maxHeight := ScreenHeight - enemyHeight - WallThickness
-		maxWidth := ScreenWidth - enemyWidth - WallThickness
-		xKhai = rand.Intn(maxWidth-min) + min
-		yKhai = rand.Intn(maxHeight-min) + min
-		game.khaiSprite[i].xLoc = xKhai
-		game.khaiSprite[i].yLoc = yKhai
-		xSophia = rand.Intn(maxWidth-min) + min
-		ySophia = rand.Intn(maxHeight-min) + min
-		game.sophiaSprite[i].xLoc = xSophia
-		game.sophiaSprite[i].yLoc = ySophia
 	}
 }
 
@@ -525,18 +771,25 @@ func (game Game) DrawEnemySprites(screen *ebiten.Image) {
 	for i := 0; i < numEnemies; i++ {
 		game.drawOps.GeoM.Reset()
 		game.drawOps.GeoM.Translate(float64(game.khaiSprite[i].xLoc), float64(game.khaiSprite[i].yLoc))
-		screen.DrawImage(game.khaiSprite[i].pict, &game.drawOps)
+		screen.DrawImage(picture(game.khaiSprite[i].pict), &game.drawOps)
 
 		game.drawOps.GeoM.Reset()
 		game.drawOps.GeoM.Translate(float64(game.sophiaSprite[i].xLoc), float64(game.sophiaSprite[i].yLoc))
-		screen.DrawImage(game.sophiaSprite[i].pict, &game.drawOps)
+		screen.DrawImage(picture(game.sophiaSprite[i].pict), &game.drawOps)
 	}
 }
 
-func (game Game) DrawPlayerSprite(screen *ebiten.Image) {
-	game.drawOps.GeoM.Reset()
-	game.drawOps.GeoM.Translate(float64(game.playerSprite.xLoc), float64(game.playerSprite.yLoc))
-	screen.DrawImage(game.playerSprite.pict, &game.drawOps)
+// DrawDogs draws every dog in the run, player two's in other colours so the players can tell them apart
+func (game Game) DrawDogs(screen *ebiten.Image) {
+	for i := 0; i < game.numDogs; i++ {
+		game.drawOps.GeoM.Reset()
+		game.drawOps.GeoM.Translate(float64(game.dogs[i].xLoc), float64(game.dogs[i].yLoc))
+		dogOps := game.drawOps
+		if i > 0 {
+			dogOps.ColorM.ChangeHSV(2*math.Pi/3, 1, 1)
+		}
+		screen.DrawImage(picture(game.dogs[i].pict), &dogOps)
+	}
 }
 
 func (game Game) Draw(screen *ebiten.Image) {
@@ -544,24 +797,26 @@ func (game Game) Draw(screen *ebiten.Image) {
 
 	if game.currentLevel != 0 && game.currentLevel != 4 {
 		// draw player
-		game.DrawPlayerSprite(screen)
+		game.DrawDogs(screen)
 
 		// draw shots
-		if game.playerSprite.activeShot == true {
-			game.drawOps.GeoM.Reset()
-			game.drawOps.GeoM.Translate(float64(game.playerSprite.Weapon.dx), float64(game.playerSprite.Weapon.dy))
-			screen.DrawImage(game.playerSprite.Weapon.pict, &game.drawOps)
+		for i := 0; i < game.numDogs; i++ {
+			if game.dogs[i].activeShot == true {
+				game.drawOps.GeoM.Reset()
+				game.drawOps.GeoM.Translate(float64(game.dogs[i].Weapon.dx), float64(game.dogs[i].Weapon.dy))
+				screen.DrawImage(picture(game.dogs[i].Weapon.pict), &game.drawOps)
+			}
 		}
 		for i := 0; i < numEnemies; i++ {
 			if game.khaiSprite[i].activeShot == true {
 				game.drawOps.GeoM.Reset()
 				game.drawOps.GeoM.Translate(float64(game.khaiSprite[i].Weapon.dx), float64(game.khaiSprite[i].Weapon.dy))
-				screen.DrawImage(game.khaiSprite[i].Weapon.pict, &game.drawOps)
+				screen.DrawImage(picture(game.khaiSprite[i].Weapon.pict), &game.drawOps)
 			}
 			if game.sophiaSprite[i].activeShot == true {
 				game.drawOps.GeoM.Reset()
 				game.drawOps.GeoM.Translate(float64(game.sophiaSprite[i].Weapon.dx), float64(game.sophiaSprite[i].Weapon.dy))
-				screen.DrawImage(game.sophiaSprite[i].Weapon.pict, &game.drawOps)
+				screen.DrawImage(picture(game.sophiaSprite[i].Weapon.pict), &game.drawOps)
 			}
 		}
 		game.drawWall(screen, game.currentLevel)
@@ -571,18 +826,30 @@ func (game Game) Draw(screen *ebiten.Image) {
 			if game.khaiSprite[i].alive == true {
 				game.drawOps.GeoM.Reset()
 				game.drawOps.GeoM.Translate(float64(game.khaiSprite[i].xLoc), float64(game.khaiSprite[i].yLoc))
-				screen.DrawImage(game.khaiSprite[i].pict, &game.drawOps)
+				screen.DrawImage(picture(game.khaiSprite[i].pict), &game.drawOps)
 			}
 			if game.sophiaSprite[i].alive == true {
 				game.drawOps.GeoM.Reset()
 				game.drawOps.GeoM.Translate(float64(game.sophiaSprite[i].xLoc), float64(game.sophiaSprite[i].yLoc))
-				screen.DrawImage(game.sophiaSprite[i].pict, &game.drawOps)
+				screen.DrawImage(picture(game.sophiaSprite[i].pict), &game.drawOps)
 			}
 		}
 		game.GameInfoBar(screen)
+		if game.aimWithMouse && !game.paused {
+			crosshairSize, _ := game.crosshair.Size()
+			game.drawOps.GeoM.Reset()
+			game.drawOps.GeoM.Translate(float64(game.dogs[game.localDog].frame.aimX-crosshairSize/2), float64(game.dogs[game.localDog].frame.aimY-crosshairSize/2))
+			screen.DrawImage(game.crosshair, &game.drawOps)
+		}
+		if game.paused {
+			game.drawPauseMenu(screen)
+		}
+		if game.net != nil && game.net.Waiting() > 500*time.Millisecond {
+			text.Draw(screen, "Waiting for the other player...", makeFont(30, 72), 300, ScreenHeight/2, colornames.Yellow)
+		}
 
 	} else if game.currentLevel == 4 { // end game
-		TopFive, LastHighScore = GetTopFive(SortedPlayers)
+		highScores := topFive(game.board.entries)
 
 		endBar := ebiten.NewImage(ScreenWidth, ScreenHeight-150)
 		endBar.Fill(colornames.Black)
@@ -590,23 +857,62 @@ func (game Game) Draw(screen *ebiten.Image) {
 		game.drawOps.GeoM.Translate(0, 0)
 		screen.DrawImage(endBar, &game.drawOps)
 		text.Draw(screen, "Game Over!", makeFont(64, 72), 300, 100, colornames.Tomato)
-		text.Draw(screen, game.infoBar.playerName, makeFont(30, 72), 200, 200, colornames.White)
-		text.Draw(screen, "score: "+strconv.Itoa(game.infoBar.score), makeFont(30, 72), 200, 250, colornames.White)
-		if game.playerSprite.lives < 0 {
+		if game.initials {
+			text.Draw(screen, "Your name: "+game.infoBar.playerName+"_", makeFont(30, 72), 200, 200, colornames.Yellow)
+			text.Draw(screen, fmt.Sprintf("You made the top %d!", arcadeTop), makeFont(30, 72), 200, 350, colornames.Yellow)
+			text.Draw(screen, "type your name or initials and press Enter", makeFont(14, 72), 200, 380, colornames.White)
+			if game.gamepads.connected() {
+				game.drawOnScreenKeyboard(screen, 420)
+			}
+		} else {
+			text.Draw(screen, game.infoBar.playerName, makeFont(30, 72), 200, 200, colornames.White)
+			game.drawPosting(screen)
+		}
+		if game.numDogs > 1 {
+			text.Draw(screen, "team score: "+strconv.Itoa(game.score), makeFont(30, 72), 200, 250, colornames.White)
+			text.Draw(screen, game.dogScores(), makeFont(16, 72), 200, 440, colornames.White)
+		} else {
+			text.Draw(screen, "score: "+strconv.Itoa(game.score), makeFont(30, 72), 200, 250, colornames.White)
+		}
+		if game.netProblem != "" {
+			text.Draw(screen, "online run ended: "+game.netProblem, makeFont(16, 72), 200, 470, colornames.Tomato)
+		}
+		if game.lost() {
 			text.Draw(screen, "You Lost!", makeFont(30, 72), 250, 300, colornames.White)
 		} else {
 			text.Draw(screen, "You Won!", makeFont(30, 72), 250, 300, colornames.White)
 		}
-		text.Draw(screen, "Current High Scores", makeFont(30, 72), 600, 200, colornames.White)
-		for i := range TopFive {
-			yAxis := 50 * i
-			text.Draw(screen, TopFive[i], makeFont(25, 72), 600, 250+yAxis, colornames.White)
-		}
-		if game.infoBar.score >= LastHighScore {
-			text.Draw(screen, "A new high Score!!", makeFont(30, 72), 200, 350, colornames.White)
+		text.Draw(screen, "High Scores - "+windowNames[game.board.query.window], makeFont(30, 72), 560, 200, colornames.White)
+		if game.scoresErr != nil {
+			text.Draw(screen, "Scores unavailable", makeFont(25, 72), 560, 250, colornames.Tomato)
+		} else {
+			for i := range highScores {
+				yAxis := 50 * i
+				xAxis := 560
+				rowColor := colornames.White
+				if i > 0 && game.board.entries[i-1].playerNum == game.infoBar.playerNum {
+					rowColor = colornames.Yellow
+					// a freshly entered arcade score slides into its place and flashes for a while
+					if slide := game.highlight - (highlightTicks - 30); slide > 0 {
+						xAxis += 14 * slide
+					}
+					if game.highlight > 0 && game.highlight/8%2 == 0 {
+						rowColor = colornames.Tomato
+					}
+				}
+				text.Draw(screen, highScores[i], makeFont(25, 72), xAxis, 250+yAxis, rowColor)
+			}
+			if game.board.hasMine {
+				placed := fmt.Sprintf("You placed #%d of %d", game.board.mine.rank, game.board.total)
+				text.Draw(screen, placed, makeFont(20, 72), 560, 250+50*len(highScores), colornames.Yellow)
+				if game.board.mine.rank <= 5 {
+					text.Draw(screen, "A new high Score!!", makeFont(30, 72), 200, 350, colornames.White)
+				}
+			}
+			text.Draw(screen, "Left / Right - all time, today, this week", makeFont(14, 72), 560, 560, colornames.White)
 		}
 
-		game.DrawPlayerSprite(screen)
+		game.DrawDogs(screen)
 		game.DrawEnemySprites(screen)
 
 	} else { // start game
@@ -617,18 +923,47 @@ func (game Game) Draw(screen *ebiten.Image) {
 		screen.DrawImage(gameBar, &game.drawOps)
 
 		playerText = game.infoBar.playerName
-		text.Draw(screen, "Welcome to "+GameTitle, makeFont(48, 72), 150, 280, textColor)
-		text.Draw(screen, GameInstructions, makeFont(14, 72), 50, 320, color.White)
-		text.Draw(screen, "Enter your name: "+game.infoBar.playerName, makeFont(20, 72), ScreenWidth-400, ScreenHeight-100, color.White)
-
-		game.drawOps.GeoM.Reset()
-		game.drawOps.GeoM.Translate(float64(game.playerSprite.xLoc), float64(game.playerSprite.yLoc))
-		screen.DrawImage(game.playerSprite.pict, &game.drawOps)
+		if game.recovering {
+			game.drawRecovery(screen)
+		} else if game.optionsOpen {
+			game.drawOptions(screen)
+		} else if game.profileOpen {
+			game.drawProfile(screen)
+		} else if game.scoresOpen {
+			game.drawHighScores(screen)
+		} else if game.lobbyOpen {
+			game.drawLobby(screen)
+		} else {
+			text.Draw(screen, "Welcome to "+GameTitle, makeFont(48, 72), 150, 280, textColor)
+			text.Draw(screen, GameInstructions+controlsText(game.bindings), makeFont(14, 72), 50, 320, color.White)
+			namePrompt := "Enter your name: "
+			if game.numDogs > 1 {
+				namePrompt = "Enter your team name: "
+			}
+			text.Draw(screen, namePrompt+game.infoBar.playerName, makeFont(20, 72), ScreenWidth-400, ScreenHeight-100, color.White)
+			if len(game.infoBar.playerName) == 0 && game.numDogs == 1 {
+				text.Draw(screen, "or just press Enter to play arcade style", makeFont(14, 72), ScreenWidth-400, ScreenHeight-70, colornames.Yellow)
+			}
+			if game.profileKnown && game.lookedUpName == game.infoBar.playerName {
+				welcome := fmt.Sprintf("Welcome back %s! %d runs, best %d, %d unlocks",
+					game.knownProfile.name, game.knownProfile.runs, game.knownProfile.best, len(game.knownProfile.unlocks))
+				text.Draw(screen, welcome, makeFont(14, 72), ScreenWidth-400, ScreenHeight-70, colornames.Yellow)
+			}
+			if game.gamepads.connected() {
+				game.drawOnScreenKeyboard(screen, ScreenHeight-90)
+			}
+		}
 
-		game.DrawPlayerSprite(screen)
+		game.DrawDogs(screen)
 		game.DrawEnemySprites(screen)
 	}
 
+	if game.loading != "" {
+		text.Draw(screen, game.loading+"...", makeFont(14, 72), 20, ScreenHeight-20, colornames.Yellow)
+	} else if game.noticeTicks > 0 {
+		text.Draw(screen, game.notice, makeFont(14, 72), 20, ScreenHeight-20, colornames.Tomato)
+	}
+
 	tps := fmt.Sprintf("TPS: %0.2f", ebiten.CurrentTPS())
 	fps := fmt.Sprintf("FPS: %0.2f", ebiten.CurrentFPS())
 	text.Draw(screen, tps+"\n"+fps, makeFont(8, 72), 950, 10, color.White)
@@ -660,10 +995,38 @@ func (game *Game) GameInfoBar(screen *ebiten.Image) {
 	infoBar.Fill(colornames.Black)
 	game.infoBar.imageBar = infoBar
 	gameFont := font.Face(inconsolata.Regular8x16)
-	text.Draw(infoBar, "Player Name: "+game.infoBar.playerName, gameFont, 20, 25, color.White)
-	text.Draw(infoBar, "#: "+strconv.Itoa(game.infoBar.playerNum), gameFont, 300, 25, color.White)
-	text.Draw(infoBar, "Score: "+strconv.Itoa(game.infoBar.score), gameFont, 450, 25, color.White)
-	text.Draw(infoBar, "Lives: "+strconv.Itoa(game.playerSprite.lives), gameFont, 720, 25, color.White)
+	if game.numDogs > 1 {
+		text.Draw(infoBar, "Team: "+game.infoBar.playerName, gameFont, 20, 25, color.White)
+	} else {
+		text.Draw(infoBar, "Player Name: "+game.infoBar.playerName, gameFont, 20, 25, color.White)
+	}
+	if game.scoresErr != nil {
+		text.Draw(infoBar, "scores unavailable", gameFont, 250, 25, colornames.Tomato)
+	} else {
+		text.Draw(infoBar, "#: "+strconv.Itoa(game.infoBar.playerNum), gameFont, 300, 25, color.White)
+	}
+	if game.numDogs > 1 { // a section for each player, the team score is theirs added up
+		for i := 0; i < game.numDogs; i++ {
+			player := fmt.Sprintf("P%d", i+1)
+			if game.net != nil && i == game.localDog {
+				player = "You"
+			}
+			status := fmt.Sprintf("%s: %d  Lives: %d", player, game.dogs[i].score, game.dogs[i].lives)
+			if !game.dogs[i].alive {
+				status = fmt.Sprintf("%s: %d  out", player, game.dogs[i].score)
+			}
+			text.Draw(infoBar, status, gameFont, 400+180*i, 25, dogColors[i])
+		}
+		if game.writer.pending(game.saving) > 0 {
+			text.Draw(infoBar, "saving...", gameFont, 760, 25, colornames.Yellow)
+		}
+	} else {
+		text.Draw(infoBar, "Score: "+strconv.Itoa(game.score), gameFont, 450, 25, color.White)
+		if game.writer.pending(game.saving) > 0 {
+			text.Draw(infoBar, "saving...", gameFont, 590, 25, colornames.Yellow)
+		}
+		text.Draw(infoBar, "Lives: "+strconv.Itoa(game.dogs[0].lives), gameFont, 720, 25, color.White)
+	}
 	text.Draw(infoBar, "Level: "+strconv.Itoa(game.currentLevel), gameFont, 850, 25, color.White)
 
 	game.drawOps.GeoM.Reset()
@@ -671,25 +1034,87 @@ func (game *Game) GameInfoBar(screen *ebiten.Image) {
 	screen.DrawImage(game.infoBar.imageBar, &game.drawOps)
 }
 
+// dogScores is what each player scored towards the team's score
+func (game Game) dogScores() string {
+	var scores []string
+	for i := 0; i < game.numDogs; i++ {
+		scores = append(scores, fmt.Sprintf("P%d: %d", i+1, game.dogs[i].score))
+	}
+	return strings.Join(scores, "    ")
+}
+
+// drawPosting says on the game over screen whether the run made it to the leaderboard server
+func (game Game) drawPosting(screen *ebiten.Image) {
+	var message string
+	postColor := color.Color(color.White)
+	switch game.posting.state {
+	case PostSending:
+		message = "Posting score..."
+	case PostPosted:
+		message, postColor = "Score posted to the leaderboard", colornames.Lightgreen
+		if game.posting.rank > 0 {
+			message += fmt.Sprintf(", #%d", game.posting.rank)
+		}
+	case PostReview:
+		message, postColor = "Score posted, waiting for review", colornames.Yellow
+	case PostWaiting:
+		message, postColor = "Leaderboard unreachable, will try again later", colornames.Yellow
+	case PostRejected:
+		message, postColor = "Score not posted: "+game.posting.message, colornames.Tomato
+	default:
+		return
+	}
+	text.Draw(screen, message, makeFont(16, 72), 200, 400, postColor)
+}
+
+func (game Game) drawPauseMenu(screen *ebiten.Image) {
+	pauseBox := ebiten.NewImage(300, 200)
+	pauseBox.Fill(colornames.Black)
+	game.drawOps.GeoM.Reset()
+	game.drawOps.GeoM.Translate(ScreenWidth/2-150, ScreenHeight/2-100)
+	screen.DrawImage(pauseBox, &game.drawOps)
+	text.Draw(screen, "Paused", makeFont(30, 72), ScreenWidth/2-60, ScreenHeight/2-50, colornames.Tomato)
+	for i := range pauseItems {
+		itemColor := color.Color(color.White)
+		if i == game.pauseCursor {
+			itemColor = colornames.Yellow
+		}
+		text.Draw(screen, pauseItems[i], makeFont(20, 72), ScreenWidth/2-40, ScreenHeight/2+10+40*i, itemColor)
+	}
+}
+
+func (game Game) drawOnScreenKeyboard(screen *ebiten.Image, top int) {
+	keyFont := makeFont(14, 72)
+	for i := range oskKeys {
+		xAxis := 50 + 34*(i%oskColumns)
+		yAxis := top + 28*(i/oskColumns)
+		keyColor := color.Color(color.White)
+		if i == game.oskCursor {
+			keyColor = colornames.Yellow
+		}
+		text.Draw(screen, oskKeys[i], keyFont, xAxis, yAxis, keyColor)
+	}
+}
+
 func (game Game) drawWall(screen *ebiten.Image, level int) {
 	// surrounding walls
 	game.drawOps.GeoM.Reset()
 	game.drawOps.GeoM.Translate(0, WallThickness*4)
-	screen.DrawImage(game.wall[0].pict, &game.drawOps)
+	screen.DrawImage(picture(game.wall[0].pict), &game.drawOps)
 	game.drawOps.GeoM.Reset()
-	screen.DrawImage(game.wall[2].pict, &game.drawOps)
+	screen.DrawImage(picture(game.wall[2].pict), &game.drawOps)
 	game.drawOps.GeoM.Reset()
 	game.drawOps.GeoM.Translate(0, ScreenHeight-WallThickness)
-	screen.DrawImage(game.wall[1].pict, &game.drawOps)
+	screen.DrawImage(picture(game.wall[1].pict), &game.drawOps)
 	game.drawOps.GeoM.Reset()
 	game.drawOps.GeoM.Translate(ScreenWidth-WallThickness, 0)
-	screen.DrawImage(game.wall[3].pict, &game.drawOps)
+	screen.DrawImage(picture(game.wall[3].pict), &game.drawOps)
 
 	// maze walls
 	for i := 0; i < game.level[level].maxWall; i++ {
 		game.drawOps.GeoM.Reset()
 		game.drawOps.GeoM.Translate(float64(game.level[level].mazeWall[i].xLoc), float64(game.level[level].mazeWall[i].yLoc))
-		screen.DrawImage(game.level[level].mazeWall[i].pict, &game.drawOps)
+		screen.DrawImage(picture(game.level[level].mazeWall[i].pict), &game.drawOps)
 	}
 
 }
@@ -701,24 +1126,45 @@ func (game Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHei
 }
 
 func main() {
+	backend := flag.String("store", defaultBackend(), "where scores are kept: sqlite, json or memory")
+	dbFlag := flag.String("db", "", "the file scores are kept in, by default $"+dbEnvVar+" or one in the user data dir")
+	flag.StringVar(&leaderboardURL, "server", os.Getenv(serverEnvVar), "leaderboard server to post finished runs to, e.g. http://host:8080")
+	flag.Parse()
+	dbfile, err := databasePath(*dbFlag, *backend)
+	if err != nil {
+		log.Println("using "+dbfile+":", err)
+	}
+	if flag.NArg() > 0 { // managing the database does not need a window
+		os.Exit(commandMain(*backend, dbfile, flag.Args()))
+	}
+	gameObject := Game{dbPath: dbfile}
+
 	// database initialization
-	defer gameDB.Close()
-	Create_tables(gameDB)
+	if *backend != BackendMemory && !DbExists(dbfile) {
+		log.Println("no database at " + dbfile + ", making a new one")
+	}
+	openScores(&gameObject, *backend)
 	// database initialization end
 
 	ebiten.SetWindowTitle(GameTitle)
 	ebiten.SetWindowSize(ScreenWidth, TotalScreenHeight)
 
-	gameObject := Game{}
 	loadImage(&gameObject)
 	rand.Seed(time.Now().UnixNano())
 
-	gameObject.playerSprite.xLoc = xStart
-	gameObject.playerSprite.yLoc = yStart
-	gameObject.playerSprite.activeShot = false
-	gameObject.playerSprite.Weapon.enemyShot = false
-	gameObject.playerSprite.lives = 3
+	gameObject.numDogs = 1
+	gameObject.dogs[0].xLoc = xStart
+	gameObject.dogs[0].yLoc = yStart
+	gameObject.dogs[0].activeShot = false
+	gameObject.dogs[0].Weapon.enemyShot = false
+	gameObject.dogs[0].lives = 3
 	gameObject.counter = 0
+	gameObject.gamepads = newGamepadInput()
+	gameObject.bindings = defaultBindings()
+	gameObject.input = multiInput{keyboardInput{gameObject.bindings}, gameObject.gamepads, mouseInput{}}
+	gameObject.partnerInput = multiInput{keyboardInput{partnerBindings()}, partnerGamepad{gameObject.gamepads}}
+	gameObject.moveConfig = defaultMoveConfig()
+	gameObject.difficulty = difficultyNamed("Normal")
 
 	enemyWidth, enemyHeight := gameObject.khaiSprite[0].pict.Size()
 
@@ -726,14 +1172,10 @@ func main() {
 		min := 50
 		maxHeight := ScreenHeight - enemyHeight - WallThickness
 		maxWidth := ScreenWidth - enemyWidth - WallThickness
-		xKhai = rand.Intn(maxWidth-min) + min
-		yKhai = rand.Intn(maxHeight-min) + min
-		gameObject.khaiSprite[i].xLoc = xKhai
-		gameObject.khaiSprite[i].yLoc = yKhai
-		xSophia = rand.Intn(maxWidth-min) + min
-		ySophia = rand.Intn(maxHeight-min) + min
-		gameObject.sophiaSprite[i].xLoc = xSophia
-		gameObject.sophiaSprite[i].yLoc = ySophia
+		gameObject.khaiSprite[i].xLoc = rand.Intn(maxWidth-min) + min
+		gameObject.khaiSprite[i].yLoc = rand.Intn(maxHeight-min) + min
+		gameObject.sophiaSprite[i].xLoc = rand.Intn(maxWidth-min) + min
+		gameObject.sophiaSprite[i].yLoc = rand.Intn(maxHeight-min) + min
 
 		gameObject.khaiSprite[i].hitWall = false
 		gameObject.khaiSprite[i].alive = true
@@ -747,12 +1189,35 @@ func main() {
 		gameObject.sophiaSprite[i].alive = true
 	}
 
-	if err := ebiten.RunGame(&gameObject); err != nil {
+	// ctrl-c and kill go through the same shutdown as quitting from the pause menu
+	gameObject.stop = make(chan os.Signal, 1)
+	signal.Notify(gameObject.stop, os.Interrupt, syscall.SIGTERM)
+
+	// RunGame also returns when the window is closed
+	err = ebiten.RunGame(&gameObject)
+	shutdown(&gameObject)
+	if err != nil && err != errGameQuit {
 		log.Fatal("Game not running", err)
 	}
 
 } // end of main
 
+// shutdown records a run that is still going as quit, waits for the queued writes and closes the database
+func shutdown(game *Game) {
+	if game.currentLevel != 0 && game.currentLevel != 4 {
+		saveScore(game)
+		endSession(game, OutcomeQuit)
+	}
+	leaveNetGame(game)
+	if game.client != nil { // runs it has not posted yet stay in the outbox for next time
+		game.client.Close()
+	}
+	game.writer.Close()
+	if err := game.store.Close(); err != nil {
+		log.Println("closing database:", err)
+	}
+}
+
 func setImage(path string) *ebiten.Image {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		fmt.Println("image path does not exist")
@@ -765,8 +1230,10 @@ func setImage(path string) *ebiten.Image {
 }
 
 func loadImage(game *Game) {
-	game.playerSprite.pict = setImage("images\\jackcharacter.png")
-	game.playerSprite.Weapon.pict = setImage("images\\frisbee.png")
+	for i := 0; i < maxDogs; i++ {
+		game.dogs[i].pict = setImage("images\\jackcharacter.png")
+		game.dogs[i].Weapon.pict = setImage("images\\frisbee.png")
+	}
 	for i := 0; i < numEnemies; i++ {
 		game.khaiSprite[i].pict = setImage("images\\dragonkhai.png")
 		game.khaiSprite[i].Weapon.pict = setImage("images\\watergun.png")
@@ -774,8 +1241,29 @@ func loadImage(game *Game) {
 		game.sophiaSprite[i].Weapon.pict = setImage("images\\watergun.png")
 	}
 
+	game.crosshair = makeCrosshair(21)
+
 	setWindowWall(game)
-	setMaze(game)
+	setMaze(&game.World, func(width int, height int) Picture { return makeWallPict(width, height) })
+}
+
+func makeCrosshair(size int) *ebiten.Image {
+	crosshair := ebiten.NewImage(size, size)
+	bar := ebiten.NewImage(size, 3)
+	bar.Fill(colornames.Crimson)
+	var ops ebiten.DrawImageOptions
+	ops.GeoM.Translate(0, float64(size/2-1))
+	crosshair.DrawImage(bar, &ops) // horizontal
+	ops.GeoM.Reset()
+	ops.GeoM.Rotate(math.Pi / 2)
+	ops.GeoM.Translate(float64(size/2+2), 0)
+	crosshair.DrawImage(bar, &ops) // vertical
+	return crosshair
+}
+
+// picture is the ebiten image behind a Picture, every Picture the game makes is one
+func picture(pict Picture) *ebiten.Image {
+	return pict.(*ebiten.Image)
 }
 
 func makeWallPict(width int, height int) *ebiten.Image {
@@ -790,71 +1278,3 @@ func setWindowWall(game *Game) {
 	game.wall[2].pict = makeWallPict(WallThickness, ScreenHeight) // left
 	game.wall[3].pict = makeWallPict(WallThickness, ScreenHeight) // right
 }
-
-func setWallLocation(x int, y int) (xD int, yD int) {
-	return x, y
-}
-
-func getWallLocation(wall Wall) (x int, y int) {
-	return wall.xLoc, wall.yLoc
-}
-
-func setMaze(game *Game) {
-
-	// level 1
-	game.level[1].level = 1
-	game.level[1].maxWall = 5
-	game.level[1].mazeWall[0].pict = makeWallPict(WallThickness, 500)
-	game.level[1].mazeWall[1].pict = makeWallPict(610, WallThickness)
-	game.level[1].mazeWall[2].pict = makeWallPict(WallThickness, 360)
-	game.level[1].mazeWall[3].pict = makeWallPict(WallThickness, 360)
-	game.level[1].mazeWall[4].pict = makeWallPict(WallThickness, 150)
-	game.level[1].mazeWall[0].xLoc, game.level[1].mazeWall[0].yLoc = setWallLocation(200, 0)
-	game.level[1].mazeWall[1].xLoc, game.level[1].mazeWall[1].yLoc = setWallLocation(200, 200)
-	game.level[1].mazeWall[2].xLoc, game.level[1].mazeWall[2].yLoc = setWallLocation(400, 390)
-	game.level[1].mazeWall[3].xLoc, game.level[1].mazeWall[3].yLoc = setWallLocation(600, 200)
-	game.level[1].mazeWall[4].xLoc, game.level[1].mazeWall[4].yLoc = setWallLocation(810, 390)
-
-	// level 2
-	game.level[2].level = 2
-	game.level[2].maxWall = 6
-	game.level[2].mazeWall[0].pict = makeWallPict(800, WallThickness)
-	game.level[2].mazeWall[0].xLoc, game.level[2].mazeWall[0].yLoc = setWallLocation(0, 200)
-	game.level[2].mazeWall[1].pict = makeWallPict(WallThickness, 180)
-	game.level[2].mazeWall[1].xLoc, game.level[2].mazeWall[1].yLoc = setWallLocation(790, 200)
-	game.level[2].mazeWall[2].pict = makeWallPict(WallThickness, 180)
-	game.level[2].mazeWall[2].xLoc, game.level[2].mazeWall[2].yLoc = setWallLocation(590, 370)
-	game.level[2].mazeWall[3].pict = makeWallPict(WallThickness, 180)
-	game.level[2].mazeWall[3].xLoc, game.level[2].mazeWall[3].yLoc = setWallLocation(390, 200)
-	game.level[2].mazeWall[4].pict = makeWallPict(WallThickness, 180)
-	game.level[2].mazeWall[4].xLoc, game.level[2].mazeWall[4].yLoc = setWallLocation(190, 370)
-	game.level[2].mazeWall[5].pict = makeWallPict(610, WallThickness)
-	game.level[2].mazeWall[5].xLoc, game.level[2].mazeWall[5].yLoc = setWallLocation(190, 540)
-
-	// level 3
-	game.level[3].level = 3
-	game.level[3].maxWall = 6
-	game.level[3].mazeWall[0].pict = makeWallPict(600, WallThickness)
-	game.level[3].mazeWall[0].xLoc, game.level[3].mazeWall[0].yLoc = setWallLocation(200, 200)
-	game.level[3].mazeWall[1].pict = makeWallPict(WallThickness, 360)
-	game.level[3].mazeWall[1].xLoc, game.level[3].mazeWall[1].yLoc = setWallLocation(200, 200)
-	game.level[3].mazeWall[2].pict = makeWallPict(WallThickness, 350)
-	game.level[3].mazeWall[2].xLoc, game.level[3].mazeWall[2].yLoc = setWallLocation(790, 200)
-	game.level[3].mazeWall[3].pict = makeWallPict(400, WallThickness)
-	game.level[3].mazeWall[3].xLoc, game.level[3].mazeWall[3].yLoc = setWallLocation(200, 550)
-	game.level[3].mazeWall[4].pict = makeWallPict(WallThickness, 210)
-	game.level[3].mazeWall[4].xLoc, game.level[3].mazeWall[4].yLoc = setWallLocation(400, 350)
-	game.level[3].mazeWall[5].pict = makeWallPict(210, WallThickness)
-	game.level[3].mazeWall[5].xLoc, game.level[3].mazeWall[5].yLoc = setWallLocation(590, 350)
-
-	// game starting window
-	game.level[0].level = 0
-	game.level[0].maxWall = 1
-	game.level[0].mazeWall[0].pict = makeWallPict(ScreenWidth, WallThickness)
-	game.level[0].mazeWall[0].xLoc, game.level[0].mazeWall[0].yLoc = setWallLocation(0, 200)
-
-	// end game
-	game.level[4].level = 4
-
-	game.currentLevel = 0
-}
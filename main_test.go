@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScoreProblemClears(t *testing.T) {
+	game := &Game{}
+	scoreProblem(game, errors.New("database is locked"))
+	if game.scoresErr == nil {
+		t.Fatal("a failed store call was not remembered")
+	}
+	store := NewMemoryStore()
+	game.store, game.writer = store, NewScoreWriter(store)
+	defer game.writer.Close()
+	queueWrite(game, "", "nothing", func(ctx context.Context, store ScoreStore) error { return nil })
+	if game.scoresErr == nil {
+		t.Fatal("queueing a write cleared the problem before the write was done")
+	}
+	ctx, cancel := dbContext()
+	defer cancel()
+	_, _, err := store.FindProfile(ctx, "nobody")
+	scoreProblem(game, err)
+	if game.scoresErr != nil {
+		t.Fatalf("still unavailable after a store call worked: %v", game.scoresErr)
+	}
+}
+
+// settle runs checkWrites until the load the game waits on is in
+func settle(t *testing.T, game *Game) {
+	t.Helper()
+	for start := time.Now(); game.loading != ""; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("still %s", game.loading)
+		}
+		checkWrites(game)
+	}
+}
+
+func TestArcadeNameIsTrimmed(t *testing.T) {
+	store := NewMemoryStore()
+	game := &Game{store: store, writer: NewScoreWriter(store), arcade: true, initials: true}
+	game.score = 700
+	game.session = newSession(game, ModeArcade)
+
+	game.infoBar.playerName = "   "
+	saveArcadeScore(game)
+	if !game.initials || game.loading != "" || game.writer.submitted != 0 {
+		t.Fatal("a name of only spaces was saved")
+	}
+
+	game.infoBar.playerName = "  Bo "
+	saveArcadeScore(game)
+	settle(t, game)
+	game.writer.Close()
+	ctx := context.Background()
+	if _, found, _ := store.FindProfile(ctx, " "); found {
+		t.Fatal("there is a profile with an empty name")
+	}
+	profile, found, err := store.FindProfile(ctx, "Bo")
+	if err != nil || !found || profile.name != "Bo" || profile.best != 700 || game.infoBar.playerName != "Bo" {
+		t.Fatalf("arcade run saved as %+v %v %v, shown as %q", profile, found, err, game.infoBar.playerName)
+	}
+}